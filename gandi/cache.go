@@ -0,0 +1,364 @@
+package gandi
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/PabloPie/go-gandi/client"
+	"github.com/PabloPie/go-gandi/hosting"
+	"golang.org/x/sync/singleflight"
+)
+
+// cachingHosting is a hosting.Hosting that remembers the ips and disks
+// listed during a provider run, every operation modifying ips, disks, vms
+// or vlans empties the cache
+//
+// Lookups of several ips or disks are answered from a single list of
+// the region, c.f ipsByID and disksByName. With prefetch_inventory, lookups
+// by ID are answered from a snapshot of the account, c.f inventory.
+//
+// Concurrent lists with the same filter share a single call, lists with
+// different filters run in parallel.
+type cachingHosting struct {
+	hosting.Hosting
+
+	// nil unless prefetch_inventory is set
+	inventory *inventory
+
+	group singleflight.Group
+
+	mu sync.Mutex
+	// Results of list calls, by filter
+	ips   map[hosting.IPFilter][]hosting.IPAddress
+	disks map[hosting.DiskFilter][]hosting.Disk
+	// Incremented by invalidate, lists started before are not cached
+	generation int
+}
+
+func newCachingHosting(h hosting.Hosting, prefetch bool) *cachingHosting {
+	c := &cachingHosting{
+		Hosting: h,
+		ips:     map[hosting.IPFilter][]hosting.IPAddress{},
+		disks:   map[hosting.DiskFilter][]hosting.Disk{},
+	}
+	if prefetch {
+		c.inventory = &inventory{}
+	}
+	return c
+}
+
+// invalidate empties the cache, it must be called after any operation
+// that modifies an ip, a disk, a vm or a vlan
+func (c *cachingHosting) invalidate() {
+	if c.inventory != nil {
+		c.inventory.invalidate()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ips = map[hosting.IPFilter][]hosting.IPAddress{}
+	c.disks = map[hosting.DiskFilter][]hosting.Disk{}
+	c.generation++
+}
+
+// Send forwards raw API calls to the client of the hosting, c.f v4Caller
+func (c *cachingHosting) Send(method string, args []interface{}, reply interface{}) error {
+	caller, ok := c.Hosting.(client.V4Caller)
+	if !ok {
+		return fmt.Errorf("[ERR] Raw API calls are not supported by this client")
+	}
+	return caller.Send(method, args, reply)
+}
+
+// ListIPs lists the ips matching the filter, once per filter
+func (c *cachingHosting) ListIPs(filter hosting.IPFilter) ([]hosting.IPAddress, error) {
+	if filter == (hosting.IPFilter{ID: filter.ID}) {
+		if ip, ok := c.ipFromInventory(filter.ID); ok {
+			return []hosting.IPAddress{ip}, nil
+		}
+	}
+	c.mu.Lock()
+	ips, ok := c.ips[filter]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok {
+		return ips, nil
+	}
+	key := fmt.Sprintf("ips/%d/%#v", generation, filter)
+	result, err, _ := c.group.Do(key, func() (interface{}, error) {
+		ips, err := c.Hosting.ListIPs(filter)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.generation == generation {
+			c.ips[filter] = ips
+		}
+		return ips, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]hosting.IPAddress), nil
+}
+
+// ListDisks lists the disks matching the filter, once per filter
+func (c *cachingHosting) ListDisks(filter hosting.DiskFilter) ([]hosting.Disk, error) {
+	if filter == (hosting.DiskFilter{ID: filter.ID}) {
+		if disk, ok := c.diskFromInventory(filter.ID); ok {
+			return []hosting.Disk{disk}, nil
+		}
+	}
+	c.mu.Lock()
+	disks, ok := c.disks[filter]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok {
+		return disks, nil
+	}
+	key := fmt.Sprintf("disks/%d/%#v", generation, filter)
+	result, err, _ := c.group.Do(key, func() (interface{}, error) {
+		disks, err := c.Hosting.ListDisks(filter)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.generation == generation {
+			c.disks[filter] = disks
+		}
+		return disks, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]hosting.Disk), nil
+}
+
+// DiskFromName returns the disk named `name`, from the cached disks of
+// every region if they were listed
+func (c *cachingHosting) DiskFromName(name string) hosting.Disk {
+	disks, err := c.ListDisks(hosting.DiskFilter{Name: name})
+	if err != nil || len(disks) < 1 {
+		return hosting.Disk{}
+	}
+	return disks[0]
+}
+
+// ipsByID returns the ips with the IDs given, in the same order, from a
+// single list of the ips of the region, ips not found are ignored
+func (c *cachingHosting) ipsByID(regionid string, ids []string) ([]hosting.IPAddress, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	regionips, err := c.ListIPs(hosting.IPFilter{RegionID: regionid})
+	if err != nil {
+		return nil, err
+	}
+	byid := map[string]hosting.IPAddress{}
+	for _, ip := range regionips {
+		byid[ip.ID] = ip
+	}
+	var ips []hosting.IPAddress
+	for _, id := range ids {
+		ip, ok := byid[id]
+		if !ok {
+			// The list of the region may be truncated
+			found, err := c.ListIPs(hosting.IPFilter{ID: id})
+			if err != nil {
+				return nil, err
+			}
+			if len(found) < 1 {
+				continue
+			}
+			ip = found[0]
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// disksByName returns the disks with the names given, in the same order,
+// from a single list of the disks of the region, disks not found are ignored
+func (c *cachingHosting) disksByName(regionid string, names []string) ([]hosting.Disk, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	regiondisks, err := c.ListDisks(hosting.DiskFilter{RegionID: regionid})
+	if err != nil {
+		return nil, err
+	}
+	byname := map[string]hosting.Disk{}
+	for _, disk := range regiondisks {
+		byname[disk.Name] = disk
+	}
+	var disks []hosting.Disk
+	for _, name := range names {
+		disk, ok := byname[name]
+		if !ok {
+			if disk = c.DiskFromName(name); disk.ID == "" {
+				continue
+			}
+		}
+		disks = append(disks, disk)
+	}
+	return disks, nil
+}
+
+// Operations modifying ips, disks, vms or vlans
+
+func (c *cachingHosting) CreateVM(vm hosting.VMSpec, image hosting.DiskImage, version hosting.IPVersion, diskSize uint) (hosting.VM, hosting.IPAddress, hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.CreateVM(vm, image, version, diskSize)
+}
+
+func (c *cachingHosting) CreateVMWithExistingIP(vm hosting.VMSpec, image hosting.DiskImage, ip hosting.IPAddress, diskSize uint) (hosting.VM, hosting.IPAddress, hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.CreateVMWithExistingIP(vm, image, ip, diskSize)
+}
+
+func (c *cachingHosting) CreateVMWithExistingDisk(vm hosting.VMSpec, version hosting.IPVersion, disk hosting.Disk) (hosting.VM, hosting.IPAddress, hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.CreateVMWithExistingDisk(vm, version, disk)
+}
+
+func (c *cachingHosting) CreateVMWithExistingDiskAndIP(vm hosting.VMSpec, ip hosting.IPAddress, disk hosting.Disk) (hosting.VM, hosting.IPAddress, hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.CreateVMWithExistingDiskAndIP(vm, ip, disk)
+}
+
+func (c *cachingHosting) AttachDisk(vm hosting.VM, disk hosting.Disk) (hosting.VM, hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.AttachDisk(vm, disk)
+}
+
+func (c *cachingHosting) AttachDiskAtPosition(vm hosting.VM, disk hosting.Disk, position int) (hosting.VM, hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.AttachDiskAtPosition(vm, disk, position)
+}
+
+func (c *cachingHosting) DetachDisk(vm hosting.VM, disk hosting.Disk) (hosting.VM, hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.DetachDisk(vm, disk)
+}
+
+func (c *cachingHosting) AttachIP(vm hosting.VM, ip hosting.IPAddress) (hosting.VM, hosting.IPAddress, error) {
+	defer c.invalidate()
+	return c.Hosting.AttachIP(vm, ip)
+}
+
+func (c *cachingHosting) DetachIP(vm hosting.VM, ip hosting.IPAddress) (hosting.VM, hosting.IPAddress, error) {
+	defer c.invalidate()
+	return c.Hosting.DetachIP(vm, ip)
+}
+
+func (c *cachingHosting) DeleteVM(vm hosting.VM) error {
+	defer c.invalidate()
+	return c.Hosting.DeleteVM(vm)
+}
+
+func (c *cachingHosting) StartVM(vm hosting.VM) error {
+	defer c.invalidate()
+	return c.Hosting.StartVM(vm)
+}
+
+func (c *cachingHosting) StopVM(vm hosting.VM) error {
+	defer c.invalidate()
+	return c.Hosting.StopVM(vm)
+}
+
+func (c *cachingHosting) RebootVM(vm hosting.VM) error {
+	defer c.invalidate()
+	return c.Hosting.RebootVM(vm)
+}
+
+func (c *cachingHosting) UpdateVMMemory(vm hosting.VM, memory int) (hosting.VM, error) {
+	defer c.invalidate()
+	return c.Hosting.UpdateVMMemory(vm, memory)
+}
+
+func (c *cachingHosting) UpdateVMCores(vm hosting.VM, cores int) (hosting.VM, error) {
+	defer c.invalidate()
+	return c.Hosting.UpdateVMCores(vm, cores)
+}
+
+func (c *cachingHosting) RenameVM(vm hosting.VM, newname string) (hosting.VM, error) {
+	defer c.invalidate()
+	return c.Hosting.RenameVM(vm, newname)
+}
+
+func (c *cachingHosting) CreateDisk(disk hosting.DiskSpec) (hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.CreateDisk(disk)
+}
+
+func (c *cachingHosting) CreateDiskFromImage(disk hosting.DiskSpec, src hosting.DiskImage) (hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.CreateDiskFromImage(disk, src)
+}
+
+func (c *cachingHosting) DeleteDisk(disk hosting.Disk) error {
+	defer c.invalidate()
+	return c.Hosting.DeleteDisk(disk)
+}
+
+func (c *cachingHosting) ExtendDisk(disk hosting.Disk, size uint) (hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.ExtendDisk(disk, size)
+}
+
+func (c *cachingHosting) RenameDisk(disk hosting.Disk, name string) (hosting.Disk, error) {
+	defer c.invalidate()
+	return c.Hosting.RenameDisk(disk, name)
+}
+
+func (c *cachingHosting) CreateIP(region hosting.Region, version hosting.IPVersion) (hosting.IPAddress, error) {
+	defer c.invalidate()
+	return c.Hosting.CreateIP(region, version)
+}
+
+func (c *cachingHosting) CreatePrivateIP(vlan hosting.Vlan, ip string) (hosting.IPAddress, error) {
+	defer c.invalidate()
+	return c.Hosting.CreatePrivateIP(vlan, ip)
+}
+
+func (c *cachingHosting) DeleteIP(ip hosting.IPAddress) error {
+	defer c.invalidate()
+	return c.Hosting.DeleteIP(ip)
+}
+
+func (c *cachingHosting) CreateVlan(vlan hosting.VlanSpec) (hosting.Vlan, error) {
+	defer c.invalidate()
+	return c.Hosting.CreateVlan(vlan)
+}
+
+func (c *cachingHosting) UpdateVlanGW(vlan hosting.Vlan, newGW string) (hosting.Vlan, error) {
+	defer c.invalidate()
+	return c.Hosting.UpdateVlanGW(vlan, newGW)
+}
+
+func (c *cachingHosting) RenameVlan(vlan hosting.Vlan, newName string) (hosting.Vlan, error) {
+	defer c.invalidate()
+	return c.Hosting.RenameVlan(vlan, newName)
+}
+
+func (c *cachingHosting) DeleteVlan(vlan hosting.Vlan) error {
+	defer c.invalidate()
+	return c.Hosting.DeleteVlan(vlan)
+}
+
+// batchLookup is implemented by the hostings able to look several ips
+// or disks up at once
+type batchLookup interface {
+	ipsByID(regionid string, ids []string) ([]hosting.IPAddress, error)
+	disksByName(regionid string, names []string) ([]hosting.Disk, error)
+}
+
+// batchLookupOf returns the batchLookup of `h`, if it has one
+func batchLookupOf(h hosting.Hosting) (batchLookup, bool) {
+	if meta, ok := h.(*providerMeta); ok {
+		h = meta.Hosting
+	}
+	b, ok := h.(batchLookup)
+	return b, ok
+}
@@ -0,0 +1,300 @@
+package gandi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PabloPie/go-gandi/hosting"
+)
+
+// testCountingHosting filters fixed lists of ips and disks and counts
+// the list calls made
+type testCountingHosting struct {
+	hosting.Hosting
+	ips   []hosting.IPAddress
+	disks []hosting.Disk
+	calls int
+}
+
+func (h *testCountingHosting) ListIPs(filter hosting.IPFilter) ([]hosting.IPAddress, error) {
+	h.calls++
+	var ips []hosting.IPAddress
+	for _, ip := range h.ips {
+		if (filter.ID == "" || ip.ID == filter.ID) && (filter.RegionID == "" || ip.RegionID == filter.RegionID) {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+func (h *testCountingHosting) ListDisks(filter hosting.DiskFilter) ([]hosting.Disk, error) {
+	h.calls++
+	var disks []hosting.Disk
+	for _, disk := range h.disks {
+		if (filter.Name == "" || disk.Name == filter.Name) && (filter.RegionID == "" || disk.RegionID == filter.RegionID) {
+			disks = append(disks, disk)
+		}
+	}
+	return disks, nil
+}
+
+func (h *testCountingHosting) AttachIP(vm hosting.VM, ip hosting.IPAddress) (hosting.VM, hosting.IPAddress, error) {
+	return vm, ip, nil
+}
+
+func TestGandi_cachingHostingIPs(t *testing.T) {
+	h := &testCountingHosting{ips: []hosting.IPAddress{
+		{ID: "1", RegionID: "1"},
+		{ID: "2", RegionID: "1"},
+		{ID: "3", RegionID: "2"},
+	}}
+	c := newCachingHosting(h, false)
+	meta := &providerMeta{Hosting: c}
+
+	iplist := []interface{}{
+		map[string]interface{}{"id": "2"},
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": "3"},
+	}
+	ips, err := parseIPS(meta, "1", iplist)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(ips) != 3 || ips[0].ID != "2" || ips[1].ID != "1" || ips[2].ID != "3" {
+		t.Fatalf("Expected ips 2, 1 and 3, got %v instead", ips)
+	}
+	// One list of the region, and one lookup of the ip of another region
+	if h.calls != 2 {
+		t.Fatalf("Expected 2 list calls, got %d instead", h.calls)
+	}
+
+	if _, err := parseIPS(meta, "1", iplist); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if h.calls != 2 {
+		t.Fatalf("Expected cached ips, got %d list calls instead", h.calls)
+	}
+
+	c.AttachIP(hosting.VM{}, ips[0])
+	if _, err := parseIPS(meta, "1", iplist); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if h.calls != 4 {
+		t.Fatalf("Expected the cache to be emptied by AttachIP, got %d list calls instead", h.calls)
+	}
+}
+
+func TestGandi_cachingHostingDisks(t *testing.T) {
+	h := &testCountingHosting{disks: []hosting.Disk{
+		{ID: "1", Name: "boot", RegionID: "1"},
+		{ID: "2", Name: "data", RegionID: "1"},
+	}}
+	meta := &providerMeta{Hosting: newCachingHosting(h, false)}
+
+	disklist := []interface{}{
+		map[string]interface{}{"name": "data"},
+		map[string]interface{}{"name": "missing"},
+		map[string]interface{}{"name": "boot"},
+	}
+	disks := parseDisks(meta, "1", disklist)
+	if len(disks) != 2 || disks[0].ID != "2" || disks[1].ID != "1" {
+		t.Fatalf("Expected disks 2 and 1, got %v instead", disks)
+	}
+	// One list of the region, and one lookup of the missing disk
+	if h.calls != 2 {
+		t.Fatalf("Expected 2 list calls, got %d instead", h.calls)
+	}
+	if disk := meta.DiskFromName("missing"); disk.ID != "" || h.calls != 2 {
+		t.Fatalf("Expected the missing disk to be cached, got %d list calls instead", h.calls)
+	}
+}
+
+func TestGandi_cachingHostingInventory(t *testing.T) {
+	h := &testCountingHosting{ips: []hosting.IPAddress{
+		{ID: "1", RegionID: "1"},
+		{ID: "2", RegionID: "1"},
+		{ID: "3", RegionID: "2"},
+	}}
+	c := newCachingHosting(h, true)
+
+	for _, id := range []string{"1", "2", "3"} {
+		ips, err := c.ListIPs(hosting.IPFilter{ID: id})
+		if err != nil || len(ips) != 1 || ips[0].ID != id {
+			t.Fatalf("Expected ip %s, got %v (%v) instead", id, ips, err)
+		}
+	}
+	if h.calls != 1 {
+		t.Fatalf("Expected a single list call, got %d instead", h.calls)
+	}
+
+	// Missing from the snapshot, looked up directly
+	if ips, _ := c.ListIPs(hosting.IPFilter{ID: "4"}); len(ips) != 0 || h.calls != 2 {
+		t.Fatalf("Expected a direct lookup of a missing ip, got %v and %d calls instead", ips, h.calls)
+	}
+
+	// Not taken again once an ip was modified
+	c.AttachIP(hosting.VM{}, hosting.IPAddress{ID: "1"})
+	c.ListIPs(hosting.IPFilter{ID: "1"})
+	c.ListIPs(hosting.IPFilter{ID: "2"})
+	if h.calls != 4 {
+		t.Fatalf("Expected direct lookups after a modification, got %d calls instead", h.calls)
+	}
+}
+
+// testBlockingHosting blocks the ip lists of region "1" until a list of
+// another region is made
+type testBlockingHosting struct {
+	hosting.Hosting
+	other chan struct{}
+	calls int32
+}
+
+func (h *testBlockingHosting) ListIPs(filter hosting.IPFilter) ([]hosting.IPAddress, error) {
+	atomic.AddInt32(&h.calls, 1)
+	if filter.RegionID != "1" {
+		close(h.other)
+		return nil, nil
+	}
+	select {
+	case <-h.other:
+		return []hosting.IPAddress{{ID: "1", RegionID: "1"}}, nil
+	case <-time.After(5 * time.Second):
+		return nil, fmt.Errorf("[ERR] List of region 1 blocked the other lists")
+	}
+}
+
+func TestGandi_cachingHostingConcurrentLists(t *testing.T) {
+	h := &testBlockingHosting{other: make(chan struct{})}
+	c := newCachingHosting(h, false)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for _, regionid := range []string{"1", "1", "2"} {
+		wg.Add(1)
+		go func(regionid string) {
+			defer wg.Done()
+			_, err := c.ListIPs(hosting.IPFilter{RegionID: regionid})
+			errs <- err
+		}(regionid)
+		if regionid == "1" {
+			// Both lists of region 1 are waiting before region 2 is listed
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	// The lists of region 1 share a single call
+	if calls := atomic.LoadInt32(&h.calls); calls != 2 {
+		t.Fatalf("Expected 2 list calls, got %d instead", calls)
+	}
+}
+
+// testPagedHosting serves the pages of vm.list, disk.list and ip.list from
+// raw API calls, and counts the calls made. IDs start at 1, vm i has disk
+// i as boot disk and ip i, the other disks and ips are detached.
+type testPagedHosting struct {
+	hosting.Hosting
+	vms   int
+	disks int
+	ips   int
+	sends int
+	infos int
+}
+
+func (h *testPagedHosting) Send(method string, args []interface{}, reply interface{}) error {
+	h.sends++
+	if strings.HasSuffix(method, ".info") {
+		// Disk sources and ip interfaces, left empty
+		return nil
+	}
+	options := args[0].(map[string]interface{})
+	first := options["page"].(int) * options["items_per_page"].(int)
+	last := first + options["items_per_page"].(int)
+	switch response := reply.(type) {
+	case *[]inventoryVM:
+		for id := first + 1; id <= last && id <= h.vms; id++ {
+			*response = append(*response, inventoryVM{ID: id, Hostname: fmt.Sprintf("vm%d", id), State: "running"})
+		}
+	case *[]inventoryDisk:
+		for id := first + 1; id <= last && id <= h.disks; id++ {
+			disk := inventoryDisk{ID: id, Name: fmt.Sprintf("disk%d", id)}
+			if id <= h.vms {
+				disk.VM = []int{id}
+				disk.BootDisk = true
+			}
+			*response = append(*response, disk)
+		}
+	case *[]inventoryIP:
+		for id := first + 1; id <= last && id <= h.ips; id++ {
+			ip := inventoryIP{ID: id, Version: 4}
+			if id <= h.vms {
+				ip.VM = id
+			}
+			*response = append(*response, ip)
+		}
+	case *[]inventoryVlan:
+	default:
+		return fmt.Errorf("[ERR] Unexpected call %s", method)
+	}
+	return nil
+}
+
+func (h *testPagedHosting) ListKeys() []hosting.SSHKey {
+	return nil
+}
+
+func (h *testPagedHosting) ListVMs(filter hosting.VMFilter) ([]hosting.VM, error) {
+	h.infos++
+	return []hosting.VM{{ID: filter.ID}}, nil
+}
+
+func TestGandi_cachingHostingInventoryPages(t *testing.T) {
+	h := &testPagedHosting{vms: 1, ips: 2*inventoryPageSize + 10}
+	c := newCachingHosting(h, true)
+
+	if ips, err := c.ListIPs(hosting.IPFilter{ID: "205"}); err != nil || len(ips) != 1 || ips[0].ID != "205" {
+		t.Fatalf("Expected ip 205, got %v (%v) instead", ips, err)
+	}
+	if h.sends != 3 {
+		t.Fatalf("Expected 3 pages of ips, got %d calls instead", h.sends)
+	}
+}
+
+func TestGandi_cachingHostingInventoryVMs(t *testing.T) {
+	n := 2*inventoryPageSize + 10
+	h := &testPagedHosting{vms: n, disks: n + 5, ips: n + 5}
+	c := newCachingHosting(h, true)
+
+	for i := 1; i <= n; i++ {
+		id := strconv.Itoa(i)
+		vms, err := c.ListVMs(hosting.VMFilter{ID: id})
+		if err != nil || len(vms) != 1 {
+			t.Fatalf("Expected vm %s, got %v (%v) instead", id, vms, err)
+		}
+		vm := vms[0]
+		if vm.ID != id || vm.Hostname != "vm"+id || len(vm.Disks) != 1 || vm.Disks[0].ID != id ||
+			len(vm.Ips) != 1 || vm.Ips[0].ID != id {
+			t.Fatalf("Expected vm %s with its disk and ip, got %v instead", id, vm)
+		}
+	}
+	// 3 pages of vms, disks and ips, no vm described
+	if h.sends != 9 || h.infos != 0 {
+		t.Fatalf("Expected 9 list calls and no vm described, got %d calls and %d vms described instead", h.sends, h.infos)
+	}
+
+	// Looked up directly once the inventory is stale
+	c.invalidate()
+	if vms, err := c.ListVMs(hosting.VMFilter{ID: "3"}); err != nil || len(vms) != 1 || h.infos != 1 {
+		t.Fatalf("Expected a direct lookup of vm 3, got %v (%v) and %d vms described instead", vms, err, h.infos)
+	}
+}
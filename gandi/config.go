@@ -0,0 +1,88 @@
+package gandi
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/PabloPie/go-gandi/client"
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// providerMeta is the meta given to every resource, it is a hosting.Hosting
+// that also carries the provider level settings
+type providerMeta struct {
+	hosting.Hosting
+
+	// client used by Hosting, c.f v4Caller
+	client client.V4Caller
+
+	// Region used by resources without region_id,
+	// empty if no default_region was configured
+	defaultRegion hosting.Region
+
+	// Disks and ips attached or detached at the same time, c.f runVMOperations
+	maxParallelOperations int
+
+	// Resources available to the account, c.f vmAccountLimits
+	limitsOnce sync.Once
+	limits     vmLimits
+	limitsErr  error
+
+	// Names of the keys gandi_ssh plans to create or rename in this run,
+	// c.f vmSSHKeysCheck
+	plannedKeysMu sync.Mutex
+	plannedKeys   map[string]bool
+}
+
+// planSSHKey records that a key named `name` is created in this run
+func (m *providerMeta) planSSHKey(name string) {
+	m.plannedKeysMu.Lock()
+	defer m.plannedKeysMu.Unlock()
+	if m.plannedKeys == nil {
+		m.plannedKeys = map[string]bool{}
+	}
+	m.plannedKeys[name] = true
+}
+
+// sshKeyPlanned tells whether gandi_ssh plans to create a key named `name`
+func sshKeyPlanned(meta interface{}, name string) bool {
+	m, ok := meta.(*providerMeta)
+	if !ok {
+		return false
+	}
+	m.plannedKeysMu.Lock()
+	defer m.plannedKeysMu.Unlock()
+	return m.plannedKeys[name]
+}
+
+// resolveRegion returns the region matching `region`, which can
+// either be a datacenter code, e.g FR-SD6, or a region ID
+func resolveRegion(h hosting.Hosting, region string) (hosting.Region, error) {
+	if _, err := strconv.Atoi(region); err != nil {
+		return h.RegionbyCode(region)
+	}
+	regions, err := h.ListRegions()
+	if err != nil {
+		return hosting.Region{}, err
+	}
+	for _, r := range regions {
+		if r.ID == region {
+			return r, nil
+		}
+	}
+	return hosting.Region{}, fmt.Errorf("[ERR] Region with ID %s not found", region)
+}
+
+// regionID returns the region_id of a resource, falling back to the
+// default_region of the provider if it was not provided
+func regionID(d *schema.ResourceData, m interface{}) (string, error) {
+	if regionid, ok := d.GetOk("region_id"); ok {
+		return regionid.(string), nil
+	}
+	if meta, ok := m.(*providerMeta); ok && meta.defaultRegion.ID != "" {
+		return meta.defaultRegion.ID, nil
+	}
+	return "", fmt.Errorf("[ERR] region_id is required when the provider has no default_region")
+}
@@ -0,0 +1,34 @@
+package gandi
+
+import (
+	"testing"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGandi_regionID(t *testing.T) {
+	withDefault := &providerMeta{defaultRegion: hosting.Region{ID: "6", Name: "FR-SD6"}}
+	withoutDefault := &providerMeta{}
+	cases := []struct {
+		raw      map[string]interface{}
+		meta     *providerMeta
+		regionid string
+		err      bool
+	}{
+		{map[string]interface{}{"region_id": "3"}, withDefault, "3", false},
+		{map[string]interface{}{"region_id": "3"}, withoutDefault, "3", false},
+		{map[string]interface{}{}, withDefault, "6", false},
+		{map[string]interface{}{}, withoutDefault, "", true},
+	}
+	for i, c := range cases {
+		d := schema.TestResourceDataRaw(t, resourceDisk().Schema, c.raw)
+		regionid, err := regionID(d, c.meta)
+		if (err != nil) != c.err {
+			t.Fatalf("Error in case %d, expected error %t, got %v instead", i, c.err, err)
+		}
+		if regionid != c.regionid {
+			t.Fatalf("Error in case %d, expected %q, got %q instead", i, c.regionid, regionid)
+		}
+	}
+}
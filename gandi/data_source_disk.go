@@ -0,0 +1,57 @@
+package gandi
+
+import (
+	"fmt"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceDisk() *schema.Resource {
+	attributes := dataSourceDiskAttributes()
+	delete(attributes, "id")
+	attributes["name"].Optional = true
+	attributes["name"].ConflictsWith = []string{"disk_id"}
+	attributes["disk_id"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		Computed:      true,
+		ConflictsWith: []string{"name"},
+	}
+	return &schema.Resource{
+		Read:   dataSourceDiskRead,
+		Schema: attributes,
+	}
+}
+
+func dataSourceDiskRead(d *schema.ResourceData, meta interface{}) error {
+	h := meta.(hosting.Hosting)
+	filter := hosting.DiskFilter{}
+	if diskid, ok := d.GetOk("disk_id"); ok {
+		filter.ID = diskid.(string)
+	} else if name, ok := d.GetOk("name"); ok {
+		filter.Name = name.(string)
+	} else {
+		return fmt.Errorf("[ERR] One of disk_id or name must be provided")
+	}
+	disks, err := h.ListDisks(filter)
+	if err != nil {
+		return err
+	}
+	if len(disks) < 1 {
+		return fmt.Errorf("[ERR] No disk matches the criteria given")
+	}
+	if len(disks) > 1 {
+		return fmt.Errorf("[ERR] %d disks match the criteria given, use more specific criteria", len(disks))
+	}
+	disk := disks[0]
+	d.SetId(disk.ID)
+	d.Set("disk_id", disk.ID)
+	for k, v := range flattenDisk(disk) {
+		if k == "id" {
+			continue
+		}
+		d.Set(k, v)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package gandi
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+func dataSourceDisks() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceDisksRead,
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.ValidateRegexp,
+			},
+			"region_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "system, image, data, backup or snapshot",
+			},
+			"detached": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Only list disks not attached to any vm",
+			},
+			// Computed
+			"ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"disks": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: dataSourceDiskAttributes(),
+				},
+			},
+		},
+	}
+}
+
+// dataSourceDiskAttributes are the attributes exported for each disk found
+func dataSourceDiskAttributes() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"name": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"region_id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"size": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Size in GB",
+		},
+		"state": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"type": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"vm_ids": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"boot_disk": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+	}
+}
+
+func dataSourceDisksRead(d *schema.ResourceData, meta interface{}) error {
+	h := meta.(hosting.Hosting)
+	filter := hosting.DiskFilter{}
+	if regionid, ok := d.GetOk("region_id"); ok {
+		filter.RegionID = regionid.(string)
+	}
+	disks, err := h.ListDisks(filter)
+	if err != nil {
+		return err
+	}
+	var r *regexp.Regexp
+	if namergx, ok := d.GetOk("name_regex"); ok {
+		r = regexp.MustCompile(namergx.(string))
+	}
+	disktype, filtertype := d.GetOk("type")
+	detached := d.Get("detached").(bool)
+
+	var ids []string
+	var disklist []map[string]interface{}
+	for _, disk := range disks {
+		if r != nil && !r.MatchString(disk.Name) {
+			continue
+		}
+		if filtertype && disk.Type != disktype.(string) {
+			continue
+		}
+		if detached && len(disk.VM) > 0 {
+			continue
+		}
+		ids = append(ids, disk.ID)
+		disklist = append(disklist, flattenDisk(disk))
+	}
+	d.SetId(strconv.Itoa(hashcode.String(strings.Join(ids, ","))))
+	d.Set("ids", ids)
+	d.Set("disks", disklist)
+	return nil
+}
+
+func flattenDisk(disk hosting.Disk) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        disk.ID,
+		"name":      disk.Name,
+		"region_id": disk.RegionID,
+		"size":      disk.Size,
+		"state":     disk.State,
+		"type":      disk.Type,
+		"vm_ids":    disk.VM,
+		"boot_disk": disk.BootDisk,
+	}
+}
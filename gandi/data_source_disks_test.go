@@ -0,0 +1,43 @@
+package gandi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/acctest"
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccGandiDisks_basic(t *testing.T) {
+	diskname := fmt.Sprintf("gandidisk%d", acctest.RandIntRange(0, 10000))
+	diskconfig := fmt.Sprintf(testAccGandiDiskNameAndSize, diskname, 10)
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGandiRegion + diskconfig + fmt.Sprintf(testAccGandiDisks, diskname),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.gandi_disks.accTestDisks", "disks.#", "1"),
+					resource.TestCheckResourceAttr("data.gandi_disks.accTestDisks", "disks.0.name", diskname),
+					resource.TestCheckResourceAttrPair("data.gandi_disk.accTestDisk", "id", "gandi_disk.accTestDisk", "id"),
+					resource.TestCheckResourceAttr("data.gandi_disk.accTestDisk", "size", "10"),
+					resource.TestCheckResourceAttr("data.gandi_disk.accTestDisk", "vm_ids.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+var testAccGandiDisks = `
+data "gandi_disks" "accTestDisks" {
+	name_regex = "^%s$"
+	region_id = "${gandi_disk.accTestDisk.region_id}"
+	type = "data"
+	detached = true
+}
+
+data "gandi_disk" "accTestDisk" {
+	disk_id = "${gandi_disk.accTestDisk.id}"
+}
+`
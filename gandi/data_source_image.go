@@ -1,51 +1,79 @@
 package gandi
 
 import (
-	"github.com/PabloPie/go-gandi/hosting"
+	"fmt"
+	"strconv"
+
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 )
 
 func dataSourceImage() *schema.Resource {
+	attributes := dataSourceImageAttributes()
+	attributes["region_id"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Required: true,
+	}
+	// Selectors
+	attributes["name"].Optional = true
+	attributes["name"].ConflictsWith = []string{"name_regex"}
+	attributes["name_regex"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		ValidateFunc:  validation.ValidateRegexp,
+		ConflictsWith: []string{"name"},
+	}
+	attributes["os_family"].Optional = true
+	attributes["most_recent"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Default:     false,
+		Description: "Use the most recent image when several match",
+	}
 	return &schema.Resource{
-		Read: dataSourceImageRead,
-		Schema: map[string]*schema.Schema{
-			"name": {
-				Type:     schema.TypeString,
-				Required: true,
-			},
-			"region_id": {
-				Type:     schema.TypeString,
-				Required: true,
-			},
-			// Computed
-			"image_id": {
-				Type:     schema.TypeString,
-				Computed: true,
-			},
-			"disk_id": {
-				Type:     schema.TypeString,
-				Computed: true,
-			},
-			"size": {
-				Type:     schema.TypeInt,
-				Computed: true,
-			},
-		},
+		Read:   dataSourceImageRead,
+		Schema: attributes,
 	}
 }
 
 func dataSourceImageRead(d *schema.ResourceData, meta interface{}) error {
-	h := meta.(hosting.Hosting)
-	region := hosting.Region{
-		ID: d.Get("region_id").(string),
-	}
-	image, err := h.ImageByName(d.Get("name").(string), region)
+	images, err := listImageInfo(meta, d.Get("region_id").(string))
 	if err != nil {
 		return err
 	}
-	d.SetId(image.ID)
-	d.Set("image_id", image.ID)
-	d.Set("disk_id", image.DiskID)
-	d.Set("size", int(image.Size))
+	images = filterImages(images, d)
+	// name is matched exactly
+	if name, ok := d.GetOk("name"); ok {
+		var filtered []imageInfo
+		for _, image := range images {
+			if image.Label == name.(string) {
+				filtered = append(filtered, image)
+			}
+		}
+		images = filtered
+	}
+	if len(images) < 1 {
+		return fmt.Errorf("[ERR] No image matches the criteria given")
+	}
+	if len(images) > 1 && !d.Get("most_recent").(bool) {
+		return fmt.Errorf("[ERR] %d images match the criteria given, use more specific criteria or set most_recent", len(images))
+	}
+	image := mostRecentImage(images)
+	for k, v := range image.flatten() {
+		d.Set(k, v)
+	}
+	d.SetId(strconv.Itoa(image.ID))
 	return nil
 }
+
+// mostRecentImage returns the image updated last, the first
+// one in case of a tie
+func mostRecentImage(images []imageInfo) imageInfo {
+	recent := images[0]
+	for _, image := range images[1:] {
+		if image.DateUpdated.After(recent.DateUpdated) {
+			recent = image
+		}
+	}
+	return recent
+}
@@ -2,6 +2,7 @@ package gandi
 
 import (
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/resource"
 )
@@ -22,9 +23,68 @@ func TestAccGandiImage_basic(t *testing.T) {
 	})
 }
 
+func TestAccGandiImage_mostRecent(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGandiRegion + testAccGandiImageMostRecent,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.gandi_image.accTestImage", "os_family", "debian"),
+					resource.TestCheckResourceAttrSet("data.gandi_image.accTestImage", "disk_id"),
+					resource.TestCheckResourceAttrSet("data.gandi_images.accTestImages", "images.0.image_id"),
+				),
+			},
+		},
+	})
+}
+
+func TestGandi_imageOSFamily(t *testing.T) {
+	cases := []struct {
+		label    string
+		osfamily string
+	}{
+		{"Debian 9", "debian"},
+		{"Ubuntu 18.04 64 bits LTS (HVM)", "ubuntu"},
+		{"", ""},
+	}
+	for i, c := range cases {
+		if osfamily := (imageInfo{Label: c.label}).osFamily(); osfamily != c.osfamily {
+			t.Fatalf("Error in case %d, expected %q, got %q instead", i, c.osfamily, osfamily)
+		}
+	}
+}
+
+func TestGandi_mostRecentImage(t *testing.T) {
+	date := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	images := []imageInfo{
+		{ID: 1, DateUpdated: date},
+		{ID: 2, DateUpdated: date.Add(48 * time.Hour)},
+		{ID: 3, DateUpdated: date.Add(24 * time.Hour)},
+	}
+	if image := mostRecentImage(images); image.ID != 2 {
+		t.Fatalf("Error, expected image 2, got %d instead", image.ID)
+	}
+}
+
 var testAccGandiImage = `
 data "gandi_image" "accTestImage" {
 	name = "Debian 9"
 	region_id = "${data.gandi_region.accTestRegion.id}"
 }
 `
+
+var testAccGandiImageMostRecent = `
+data "gandi_image" "accTestImage" {
+	name_regex = "^Debian"
+	os_family = "debian"
+	most_recent = true
+	region_id = "${data.gandi_region.accTestRegion.id}"
+}
+
+data "gandi_images" "accTestImages" {
+	os_family = "debian"
+	region_id = "${data.gandi_region.accTestRegion.id}"
+}
+`
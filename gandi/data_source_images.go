@@ -0,0 +1,227 @@
+package gandi
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+func dataSourceImages() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceImagesRead,
+		Schema: map[string]*schema.Schema{
+			"region_id": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			// Filters
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.ValidateRegexp,
+			},
+			"os_family": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			// Computed
+			"ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"images": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: dataSourceImageAttributes(),
+				},
+			},
+		},
+	}
+}
+
+// dataSourceImageAttributes are the attributes exported for each image found
+func dataSourceImageAttributes() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"image_id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"name": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"disk_id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"size": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Size in GB",
+		},
+		"os_family": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"kernel": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"architecture": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"visibility": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"date": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Date of the last update of the image, RFC3339",
+		},
+		"licenses": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+	}
+}
+
+func dataSourceImagesRead(d *schema.ResourceData, meta interface{}) error {
+	images, err := listImageInfo(meta, d.Get("region_id").(string))
+	if err != nil {
+		return err
+	}
+	images = filterImages(images, d)
+	var ids []string
+	var imagelist []map[string]interface{}
+	for _, image := range images {
+		ids = append(ids, strconv.Itoa(image.ID))
+		imagelist = append(imagelist, image.flatten())
+	}
+	d.SetId(strconv.Itoa(hashcode.String(strings.Join(ids, ","))))
+	d.Set("ids", ids)
+	d.Set("images", imagelist)
+	return nil
+}
+
+// imageInfo is an image as returned by the API, the hosting library
+// only exposes its ID, disk, region, name and size
+type imageInfo struct {
+	ID            int            `xmlrpc:"id"`
+	DiskID        int            `xmlrpc:"disk_id"`
+	RegionID      int            `xmlrpc:"datacenter_id"`
+	Label         string         `xmlrpc:"label"`
+	Size          int            `xmlrpc:"size"` // in MB
+	OSArch        string         `xmlrpc:"os_arch"`
+	KernelVersion string         `xmlrpc:"kernel_version"`
+	Visibility    string         `xmlrpc:"visibility"`
+	DateUpdated   time.Time      `xmlrpc:"date_updated"`
+	Licenses      []imageLicense `xmlrpc:"licenses"`
+}
+
+type imageLicense struct {
+	ID   int    `xmlrpc:"id"`
+	Name string `xmlrpc:"name"`
+}
+
+// osFamily is the lowercase first word of the label of an image,
+// e.g "Debian 9" belongs to the "debian" family
+func (i imageInfo) osFamily() string {
+	fields := strings.Fields(i.Label)
+	if len(fields) < 1 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+func (i imageInfo) flatten() map[string]interface{} {
+	date := ""
+	if !i.DateUpdated.IsZero() {
+		date = i.DateUpdated.Format(time.RFC3339)
+	}
+	var licenses []string
+	for _, license := range i.Licenses {
+		licenses = append(licenses, license.Name)
+	}
+	return map[string]interface{}{
+		"image_id":     strconv.Itoa(i.ID),
+		"name":         i.Label,
+		"disk_id":      strconv.Itoa(i.DiskID),
+		"size":         i.Size / 1024,
+		"os_family":    i.osFamily(),
+		"kernel":       i.KernelVersion,
+		"architecture": i.OSArch,
+		"visibility":   i.Visibility,
+		"date":         date,
+		"licenses":     licenses,
+	}
+}
+
+// filterImages keeps the images matching the name_regex and os_family
+// given to the data source
+func filterImages(images []imageInfo, d *schema.ResourceData) []imageInfo {
+	var r *regexp.Regexp
+	if namergx, ok := d.GetOk("name_regex"); ok {
+		r = regexp.MustCompile(namergx.(string))
+	}
+	osfamily, filterfamily := d.GetOk("os_family")
+	var filtered []imageInfo
+	for _, image := range images {
+		if r != nil && !r.MatchString(image.Label) {
+			continue
+		}
+		if filterfamily && !strings.EqualFold(image.osFamily(), osfamily.(string)) {
+			continue
+		}
+		filtered = append(filtered, image)
+	}
+	return filtered
+}
+
+// listImageInfo lists every image of a region, calling the API directly
+// when possible to get the full description of each image
+func listImageInfo(meta interface{}, regionid string) ([]imageInfo, error) {
+	if caller, ok := v4Caller(meta); ok {
+		id, err := strconv.Atoi(regionid)
+		if err != nil {
+			return nil, err
+		}
+		filter := map[string]interface{}{"datacenter_id": id}
+		var response []imageInfo
+		if err := caller.Send("hosting.image.list", []interface{}{filter}, &response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	h := meta.(hosting.Hosting)
+	images, err := h.ListImagesInRegion(hosting.Region{ID: regionid})
+	if err != nil {
+		return nil, err
+	}
+	var response []imageInfo
+	for _, image := range images {
+		id, _ := strconv.Atoi(image.ID)
+		diskid, _ := strconv.Atoi(image.DiskID)
+		response = append(response, imageInfo{
+			ID:     id,
+			DiskID: diskid,
+			Label:  image.Name,
+			Size:   image.Size * 1024,
+		})
+	}
+	return response, nil
+}
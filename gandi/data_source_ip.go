@@ -0,0 +1,57 @@
+package gandi
+
+import (
+	"fmt"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceIP() *schema.Resource {
+	attributes := dataSourceIPAttributes()
+	delete(attributes, "id")
+	attributes["ip"].Optional = true
+	attributes["ip"].ConflictsWith = []string{"ip_id"}
+	attributes["ip_id"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		Computed:      true,
+		ConflictsWith: []string{"ip"},
+	}
+	return &schema.Resource{
+		Read:   dataSourceIPRead,
+		Schema: attributes,
+	}
+}
+
+func dataSourceIPRead(d *schema.ResourceData, meta interface{}) error {
+	h := meta.(hosting.Hosting)
+	filter := hosting.IPFilter{}
+	if ipid, ok := d.GetOk("ip_id"); ok {
+		filter.ID = ipid.(string)
+	} else if ip, ok := d.GetOk("ip"); ok {
+		filter.IP = ip.(string)
+	} else {
+		return fmt.Errorf("[ERR] One of ip_id or ip must be provided")
+	}
+	ips, err := h.ListIPs(filter)
+	if err != nil {
+		return err
+	}
+	if len(ips) < 1 {
+		return fmt.Errorf("[ERR] No ip matches the criteria given")
+	}
+	if len(ips) > 1 {
+		return fmt.Errorf("[ERR] %d ips match the criteria given, use more specific criteria", len(ips))
+	}
+	ip := ips[0]
+	d.SetId(ip.ID)
+	d.Set("ip_id", ip.ID)
+	for k, v := range flattenIP(ip) {
+		if k == "id" {
+			continue
+		}
+		d.Set(k, v)
+	}
+	return nil
+}
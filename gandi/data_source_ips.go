@@ -0,0 +1,145 @@
+package gandi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceIPs() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceIPsRead,
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"region_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"version": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					v := val.(int)
+					if v != 4 && v != 6 {
+						errs = append(errs, fmt.Errorf("%q must be either 4 or 6, got: %d", key, v))
+					}
+					return
+				},
+			},
+			"state": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"detached": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Only list ips not attached to any vm",
+			},
+			// Computed
+			"ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"ips": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: dataSourceIPAttributes(),
+				},
+			},
+		},
+	}
+}
+
+// dataSourceIPAttributes are the attributes exported for each ip found
+func dataSourceIPAttributes() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"ip": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"region_id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"version": {
+			Type:     schema.TypeInt,
+			Computed: true,
+		},
+		"state": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"vm_id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+	}
+}
+
+func dataSourceIPsRead(d *schema.ResourceData, meta interface{}) error {
+	h := meta.(hosting.Hosting)
+	filter := hosting.IPFilter{}
+	if regionid, ok := d.GetOk("region_id"); ok {
+		filter.RegionID = regionid.(string)
+	}
+	if version, ok := d.GetOk("version"); ok {
+		filter.Version = hosting.IPVersion(version.(int))
+	}
+	ips, err := h.ListIPs(filter)
+	if err != nil {
+		return err
+	}
+	state, filterstate := d.GetOk("state")
+	detached := d.Get("detached").(bool)
+
+	var ids []string
+	var iplist []map[string]interface{}
+	for _, ip := range ips {
+		if filterstate && ip.State != state.(string) {
+			continue
+		}
+		if detached && ipAttached(ip) {
+			continue
+		}
+		ids = append(ids, ip.ID)
+		iplist = append(iplist, flattenIP(ip))
+	}
+	d.SetId(strconv.Itoa(hashcode.String(strings.Join(ids, ","))))
+	d.Set("ids", ids)
+	d.Set("ips", iplist)
+	return nil
+}
+
+// ipAttached returns true if the ip is attached to a vm, the vm
+// of a detached ip is either empty or "0"
+func ipAttached(ip hosting.IPAddress) bool {
+	return ip.VM != "" && ip.VM != "0"
+}
+
+func flattenIP(ip hosting.IPAddress) map[string]interface{} {
+	vmid := ""
+	if ipAttached(ip) {
+		vmid = ip.VM
+	}
+	return map[string]interface{}{
+		"id":        ip.ID,
+		"ip":        ip.IP,
+		"region_id": ip.RegionID,
+		"version":   int(ip.Version),
+		"state":     ip.State,
+		"vm_id":     vmid,
+	}
+}
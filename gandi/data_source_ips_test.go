@@ -0,0 +1,37 @@
+package gandi
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccGandiIPs_detached(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGandiRegion + testAccGandiIPv6 + testAccGandiIPs,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.gandi_ip.accTestIP", "id", "gandi_ip.accTestIP", "id"),
+					resource.TestCheckResourceAttrPair("data.gandi_ip.accTestIP", "ip_id", "gandi_ip.accTestIP", "id"),
+					resource.TestCheckResourceAttr("data.gandi_ip.accTestIP", "vm_id", ""),
+					resource.TestCheckResourceAttr("data.gandi_ips.accTestIPs", "ips.0.vm_id", ""),
+				),
+			},
+		},
+	})
+}
+
+var testAccGandiIPs = `
+data "gandi_ip" "accTestIP" {
+	ip = "${gandi_ip.accTestIP.ip}"
+}
+
+data "gandi_ips" "accTestIPs" {
+	region_id = "${gandi_ip.accTestIP.region_id}"
+	version = 6
+	detached = true
+}
+`
@@ -0,0 +1,164 @@
+package gandi
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+// Region status, derived from the closing date reported by Gandi
+const (
+	regionActive  = "active"
+	regionClosing = "closing"
+	regionClosed  = "closed"
+)
+
+func dataSourceRegions() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceRegionsRead,
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"country": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"status": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{regionActive, regionClosing, regionClosed}, false),
+			},
+			// Computed
+			"ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"regions": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"code": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"country": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"closing_date": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Date the region stops being available, RFC3339",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// regionInfo is a region as returned by the API, the hosting library
+// does not expose its name nor its closing date
+type regionInfo struct {
+	ID           int       `xmlrpc:"id"`
+	Code         string    `xmlrpc:"dc_code"`
+	Name         string    `xmlrpc:"name"`
+	Country      string    `xmlrpc:"country"`
+	DeactivateAt time.Time `xmlrpc:"deactivate_at"`
+}
+
+func (r regionInfo) status(now time.Time) string {
+	switch {
+	case r.DeactivateAt.IsZero():
+		return regionActive
+	case r.DeactivateAt.After(now):
+		return regionClosing
+	}
+	return regionClosed
+}
+
+func dataSourceRegionsRead(d *schema.ResourceData, meta interface{}) error {
+	regions, err := listRegionInfo(meta)
+	if err != nil {
+		return err
+	}
+	country, filtercountry := d.GetOk("country")
+	status, filterstatus := d.GetOk("status")
+	now := time.Now()
+
+	var ids []string
+	var regionlist []map[string]interface{}
+	for _, region := range regions {
+		if filtercountry && !strings.EqualFold(region.Country, country.(string)) {
+			continue
+		}
+		if filterstatus && region.status(now) != status.(string) {
+			continue
+		}
+		closingdate := ""
+		if !region.DeactivateAt.IsZero() {
+			closingdate = region.DeactivateAt.Format(time.RFC3339)
+		}
+		id := strconv.Itoa(region.ID)
+		ids = append(ids, id)
+		regionlist = append(regionlist, map[string]interface{}{
+			"id":           id,
+			"code":         region.Code,
+			"name":         region.Name,
+			"country":      region.Country,
+			"status":       region.status(now),
+			"closing_date": closingdate,
+		})
+	}
+	d.SetId(strconv.Itoa(hashcode.String(strings.Join(ids, ","))))
+	d.Set("ids", ids)
+	d.Set("regions", regionlist)
+	return nil
+}
+
+// listRegionInfo lists every region, calling the API directly when possible
+// to get the full description of each region
+func listRegionInfo(meta interface{}) ([]regionInfo, error) {
+	if caller, ok := v4Caller(meta); ok {
+		var response []regionInfo
+		if err := caller.Send("hosting.datacenter.list", []interface{}{}, &response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	h := meta.(hosting.Hosting)
+	regions, err := h.ListRegions()
+	if err != nil {
+		return nil, err
+	}
+	var response []regionInfo
+	for _, region := range regions {
+		id, err := strconv.Atoi(region.ID)
+		if err != nil {
+			return nil, err
+		}
+		// Region.Name is the datacenter code
+		response = append(response, regionInfo{ID: id, Code: region.Name, Name: region.Name, Country: region.Country})
+	}
+	return response, nil
+}
@@ -0,0 +1,48 @@
+package gandi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestGandi_regionStatus(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		region regionInfo
+		status string
+	}{
+		{regionInfo{}, regionActive},
+		{regionInfo{DeactivateAt: now.Add(24 * time.Hour)}, regionClosing},
+		{regionInfo{DeactivateAt: now.Add(-24 * time.Hour)}, regionClosed},
+	}
+	for i, c := range cases {
+		if status := c.region.status(now); status != c.status {
+			t.Fatalf("Error in case %d, expected %s, got %s instead", i, c.status, status)
+		}
+	}
+}
+
+func TestAccGandiRegions_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGandiRegions,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.gandi_regions.accTestRegions", "regions.0.country", "France"),
+					resource.TestCheckResourceAttr("data.gandi_regions.accTestRegions", "regions.0.status", "active"),
+				),
+			},
+		},
+	})
+}
+
+var testAccGandiRegions = `
+data "gandi_regions" "accTestRegions" {
+	country = "France"
+	status = "active"
+}
+`
@@ -0,0 +1,58 @@
+package gandi
+
+import (
+	"fmt"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceSSHKey() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceSSHKeyRead,
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ConflictsWith: []string{"fingerprint"},
+			},
+			"fingerprint": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ConflictsWith: []string{"name"},
+			},
+			// Computed
+			"value": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceSSHKeyRead(d *schema.ResourceData, meta interface{}) error {
+	h := meta.(hosting.Hosting)
+	var sshkey hosting.SSHKey
+	if name, ok := d.GetOk("name"); ok {
+		sshkey = h.KeyFromName(name.(string))
+	} else if fingerprint, ok := d.GetOk("fingerprint"); ok {
+		for _, key := range h.ListKeys() {
+			if sshKeyMatchesFingerprint(key, fingerprint.(string)) {
+				sshkey = key
+				break
+			}
+		}
+	} else {
+		return fmt.Errorf("[ERR] One of name or fingerprint must be provided")
+	}
+	if sshkey.ID == "" {
+		return fmt.Errorf("[ERR] SSH key not found")
+	}
+	d.SetId(sshkey.ID)
+	d.Set("name", sshkey.Name)
+	d.Set("fingerprint", sshkey.Fingerprint)
+	d.Set("value", sshkey.Value)
+	return nil
+}
@@ -0,0 +1,70 @@
+package gandi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/acctest"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestAccGandiSSHKey_basic(t *testing.T) {
+	keyname := acctest.RandomWithPrefix("gandissh")
+	keyvalue, _, _ := acctest.RandSSHKeyPair("")
+	keyconfig := fmt.Sprintf(testAccGandiSSHBasic, keyname, keyvalue+"nonexistentmail")
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: keyconfig + testAccGandiSSHKey,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.gandi_ssh_key.byName", "id", "gandi_ssh.accTestSSH", "id"),
+					resource.TestCheckResourceAttrPair("data.gandi_ssh_key.byFingerprint", "name", "gandi_ssh.accTestSSH", "name"),
+				),
+			},
+		},
+	})
+}
+
+func TestGandi_dataSourceSSHKeyRead(t *testing.T) {
+	ed25519, _ := parseSSHPublicKey(testSSHKeyEd25519)
+	h := testKeyHosting{keys: []hosting.SSHKey{
+		{ID: "1", Name: "laptop", Value: testSSHKeyEd25519, Fingerprint: ed25519.fingerprint()},
+		{ID: "2", Name: "deploy", Value: testSSHKeyRSA2048, Fingerprint: "bf:6e:22:5b:d9:c7:91:b1:7b:7b:ae:85:a0:db:1e:e9"},
+	}}
+	cases := []struct {
+		Fingerprint string
+		ID          string
+	}{
+		{"bf:6e:22:5b:d9:c7:91:b1:7b:7b:ae:85:a0:db:1e:e9", "2"},
+		{"bf6e225bd9c791b17b7bae85a0db1ee9", "2"},
+		{"MD5:BF:6E:22:5B:D9:C7:91:B1:7B:7B:AE:85:A0:DB:1E:E9", "2"},
+		{ed25519.fingerprintSHA256(), "1"},
+		{"00:11:22:33", ""},
+	}
+	for i, c := range cases {
+		d := schema.TestResourceDataRaw(t, dataSourceSSHKey().Schema, map[string]interface{}{
+			"fingerprint": c.Fingerprint,
+		})
+		err := dataSourceSSHKeyRead(d, h)
+		if (err != nil) != (c.ID == "") {
+			t.Fatalf("Error in case %d, unexpected error %v", i, err)
+		}
+		if d.Id() != c.ID {
+			t.Fatalf("Error in case %d, expected key %s, got %s instead", i, c.ID, d.Id())
+		}
+	}
+}
+
+var testAccGandiSSHKey = `
+data "gandi_ssh_key" "byName" {
+	name = "${gandi_ssh.accTestSSH.name}"
+}
+
+data "gandi_ssh_key" "byFingerprint" {
+	fingerprint = "${gandi_ssh.accTestSSH.fingerprint}"
+}
+`
@@ -0,0 +1,104 @@
+package gandi
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceVlan() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVlanRead,
+		Schema: map[string]*schema.Schema{
+			"vlan_id": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ConflictsWith: []string{"name"},
+			},
+			"name": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ConflictsWith: []string{"vlan_id"},
+			},
+			// Computed
+			"region_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"subnet": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"gateway": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"private_ips": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Private ips created in the vlan",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceVlanRead(d *schema.ResourceData, meta interface{}) error {
+	h := meta.(hosting.Hosting)
+	var vlan hosting.Vlan
+	if vlanid, ok := d.GetOk("vlan_id"); ok {
+		vlans, err := h.ListVlans(hosting.VlanFilter{ID: []string{vlanid.(string)}})
+		if err != nil {
+			return err
+		}
+		if len(vlans) < 1 {
+			return fmt.Errorf("[ERR] Vlan with ID %s not found", vlanid)
+		}
+		vlan = vlans[0]
+	} else if name, ok := d.GetOk("name"); ok {
+		var err error
+		if vlan, err = h.VlanFromName(name.(string)); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("[ERR] One of vlan_id or name must be provided")
+	}
+	privateips, err := vlanPrivateIPs(h, vlan)
+	if err != nil {
+		return err
+	}
+	d.SetId(vlan.ID)
+	d.Set("vlan_id", vlan.ID)
+	d.Set("name", vlan.Name)
+	d.Set("region_id", vlan.RegionID)
+	d.Set("subnet", vlan.Subnet)
+	d.Set("gateway", vlan.Gateway)
+	d.Set("private_ips", privateips)
+	return nil
+}
+
+// vlanPrivateIPs returns the ips of the region that belong to the
+// subnet of the vlan, ips do not reference the vlan they were created in
+func vlanPrivateIPs(h hosting.Hosting, vlan hosting.Vlan) ([]string, error) {
+	_, subnet, err := net.ParseCIDR(vlan.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("[ERR] Invalid subnet '%s' for vlan '%s': %s", vlan.Subnet, vlan.Name, err)
+	}
+	ips, err := h.ListIPs(hosting.IPFilter{RegionID: vlan.RegionID, Version: hosting.IPv4})
+	if err != nil {
+		return nil, err
+	}
+	var privateips []string
+	for _, ip := range ips {
+		if subnet.Contains(net.ParseIP(ip.IP)) {
+			privateips = append(privateips, ip.IP)
+		}
+	}
+	return privateips, nil
+}
@@ -0,0 +1,53 @@
+package gandi
+
+import (
+	"fmt"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+func dataSourceVM() *schema.Resource {
+	attributes := dataSourceVMAttributes()
+	delete(attributes, "id")
+	// Filters, every attribute used as filter is also set from the vm found
+	attributes["name"].Optional = true
+	attributes["farm"].Optional = true
+	attributes["region_id"].Optional = true
+	attributes["state"].Optional = true
+	attributes["name_regex"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		ValidateFunc:  validation.ValidateRegexp,
+		ConflictsWith: []string{"name"},
+	}
+	return &schema.Resource{
+		Read:   dataSourceVMRead,
+		Schema: attributes,
+	}
+}
+
+// A single vm must match the filters given
+func dataSourceVMRead(d *schema.ResourceData, meta interface{}) error {
+	h := meta.(hosting.Hosting)
+	vms, err := listVMsFiltered(h, d)
+	if err != nil {
+		return err
+	}
+	if len(vms) < 1 {
+		return fmt.Errorf("[ERR] No vm matches the criteria given")
+	}
+	if len(vms) > 1 {
+		return fmt.Errorf("[ERR] %d vms match the criteria given, use more specific criteria", len(vms))
+	}
+	vm := vms[0]
+	d.SetId(vm.ID)
+	for k, v := range flattenVM(vm) {
+		if k == "id" {
+			continue
+		}
+		d.Set(k, v)
+	}
+	return nil
+}
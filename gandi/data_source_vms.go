@@ -0,0 +1,212 @@
+package gandi
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+func dataSourceVMs() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVMsRead,
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.ValidateRegexp,
+			},
+			"farm": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"region_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"state": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			// Computed
+			"ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"vms": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: dataSourceVMAttributes(),
+				},
+			},
+		},
+	}
+}
+
+// dataSourceVMAttributes are the attributes exported for each vm found
+func dataSourceVMAttributes() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"name": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"region_id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"farm": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"state": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"memory": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Memory in MB",
+		},
+		"cores": {
+			Type:     schema.TypeInt,
+			Computed: true,
+		},
+		"ips": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"id": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"ip": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"version": {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+				},
+			},
+		},
+		"disks": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "Disks attached to the vm, the first one is the boot disk",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"id": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"size": {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceVMsRead(d *schema.ResourceData, meta interface{}) error {
+	h := meta.(hosting.Hosting)
+	vms, err := listVMsFiltered(h, d)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	var vmlist []map[string]interface{}
+	for _, vm := range vms {
+		ids = append(ids, vm.ID)
+		vmlist = append(vmlist, flattenVM(vm))
+	}
+	d.SetId(strconv.Itoa(hashcode.String(strings.Join(ids, ","))))
+	d.Set("ids", ids)
+	d.Set("vms", vmlist)
+	return nil
+}
+
+// listVMsFiltered lists the vms matching the filters of a vm data source,
+// every filter but name_regex is applied by the API
+func listVMsFiltered(h hosting.Hosting, d *schema.ResourceData) ([]hosting.VM, error) {
+	filter := hosting.VMFilter{}
+	// name is only a filter of gandi_vm
+	if name, ok := d.GetOk("name"); ok {
+		filter.Hostname = name.(string)
+	}
+	if farm, ok := d.GetOk("farm"); ok {
+		filter.Farm = farm.(string)
+	}
+	if regionid, ok := d.GetOk("region_id"); ok {
+		filter.RegionID = regionid.(string)
+	}
+	if state, ok := d.GetOk("state"); ok {
+		filter.State = state.(string)
+	}
+	vms, err := h.ListVMs(filter)
+	if err != nil {
+		return nil, err
+	}
+	namergx, ok := d.GetOk("name_regex")
+	if !ok {
+		return vms, nil
+	}
+	r := regexp.MustCompile(namergx.(string))
+	var filtered []hosting.VM
+	for _, vm := range vms {
+		if r.MatchString(vm.Hostname) {
+			filtered = append(filtered, vm)
+		}
+	}
+	return filtered, nil
+}
+
+func flattenVM(vm hosting.VM) map[string]interface{} {
+	var ips []map[string]interface{}
+	for _, ip := range vm.Ips {
+		ips = append(ips, map[string]interface{}{
+			"id":      ip.ID,
+			"ip":      ip.IP,
+			"version": int(ip.Version),
+		})
+	}
+	var disks []map[string]interface{}
+	for _, disk := range vm.Disks {
+		disks = append(disks, map[string]interface{}{
+			"id":   disk.ID,
+			"name": disk.Name,
+			"size": disk.Size,
+		})
+	}
+	return map[string]interface{}{
+		"id":        vm.ID,
+		"name":      vm.Hostname,
+		"region_id": vm.RegionID,
+		"farm":      vm.Farm,
+		"state":     vm.State,
+		"memory":    vm.Memory,
+		"cores":     vm.Cores,
+		"ips":       ips,
+		"disks":     disks,
+	}
+}
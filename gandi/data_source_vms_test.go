@@ -0,0 +1,43 @@
+package gandi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/acctest"
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccGandiVMs_basic(t *testing.T) {
+	vmname := fmt.Sprintf("gandivm-%d", acctest.RandIntRange(1, 1000))
+	vmconfig := fmt.Sprintf(testAccGandiVMbasic, vmname)
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: vmconfig + fmt.Sprintf(testAccGandiVMs, vmname),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.gandi_vms.accTestVMs", "vms.#", "1"),
+					resource.TestCheckResourceAttr("data.gandi_vms.accTestVMs", "vms.0.name", vmname),
+					resource.TestCheckResourceAttrPair("data.gandi_vms.accTestVMs", "ids.0", "gandi_vm.accTestVM", "id"),
+					resource.TestCheckResourceAttrPair("data.gandi_vm.accTestVM", "id", "gandi_vm.accTestVM", "id"),
+					resource.TestCheckResourceAttr("data.gandi_vm.accTestVM", "ips.#", "2"),
+				),
+			},
+		},
+	})
+}
+
+var testAccGandiVMs = `
+data "gandi_vms" "accTestVMs" {
+	name_regex = "^%[1]s$"
+	region_id = "${gandi_vm.accTestVM.region_id}"
+}
+
+data "gandi_vm" "accTestVM" {
+	name = "%[1]s"
+	state = "running"
+	depends_on = ["gandi_vm.accTestVM"]
+}
+`
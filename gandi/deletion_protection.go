@@ -0,0 +1,102 @@
+package gandi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// deletionProtectionSchema is shared by every resource that can be protected
+// against destruction
+func deletionProtectionSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Default:     false,
+		Description: "Prevent the resource from being destroyed or replaced",
+	}
+}
+
+// deletionProtectionError is returned when a protected resource would be
+// destroyed, the protection must be disabled in a separate apply
+func deletionProtectionError(id string) error {
+	return fmt.Errorf("[ERR] Resource '%s' has deletion_protection enabled, "+
+		"set it to false and apply before destroying or replacing it", id)
+}
+
+// checkDeletionProtection is called before any operation destroying
+// a resource
+func checkDeletionProtection(d *schema.ResourceData) error {
+	if d.Get("deletion_protection").(bool) {
+		return deletionProtectionError(d.Id())
+	}
+	return nil
+}
+
+// deletionProtectionCheck fails the plan when an attribute forcing a new
+// resource, i.e ForceNew in `s`, or any of `keys`, the attributes replacing
+// the resource in its Update, changes on a protected resource
+//
+// The value in the state is the one checked, so disabling the protection
+// in the same plan is not enough
+func deletionProtectionCheck(s map[string]*schema.Schema, keys ...string) schema.CustomizeDiffFunc {
+	return func(d *schema.ResourceDiff, meta interface{}) error {
+		if d.Id() == "" {
+			return nil
+		}
+		if protected, _ := d.GetChange("deletion_protection"); !protected.(bool) {
+			return nil
+		}
+		changed := d.GetChangedKeysPrefix("")
+		sort.Strings(changed)
+		for _, key := range changed {
+			if forcesNew(s, key) {
+				return deletionProtectionReplaceError(strings.SplitN(key, ".", 2)[0], d.Id())
+			}
+		}
+		for _, key := range keys {
+			if d.HasChange(key) {
+				return deletionProtectionReplaceError(key, d.Id())
+			}
+		}
+		return nil
+	}
+}
+
+// deletionProtectionForceNew forces a new resource from a CustomizeDiff
+// when `key` changes, unless the resource is protected
+func deletionProtectionForceNew(d *schema.ResourceDiff, key string) error {
+	if protected, _ := d.GetChange("deletion_protection"); protected.(bool) {
+		return deletionProtectionReplaceError(key, d.Id())
+	}
+	return d.ForceNew(key)
+}
+
+func deletionProtectionReplaceError(key, id string) error {
+	return fmt.Errorf("[ERR] Changing '%s' would replace resource '%s' "+
+		"but deletion_protection is enabled, set it to false and apply first", key, id)
+}
+
+// forcesNew tells whether the attribute at `key`, e.g "userpass.0.login",
+// or any block containing it is ForceNew in `s`
+func forcesNew(s map[string]*schema.Schema, key string) bool {
+	parts := strings.Split(key, ".")
+	// Every other part is the index of an element of a list or a set
+	for i := 0; i < len(parts); i += 2 {
+		attr, ok := s[parts[i]]
+		if !ok {
+			return false
+		}
+		if attr.ForceNew {
+			return true
+		}
+		elem, ok := attr.Elem.(*schema.Resource)
+		if !ok {
+			return false
+		}
+		s = elem.Schema
+	}
+	return false
+}
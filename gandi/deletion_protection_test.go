@@ -0,0 +1,75 @@
+package gandi
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestGandi_forcesNew(t *testing.T) {
+	s := resourceVM().Schema
+	cases := []struct {
+		key       string
+		forcesnew bool
+	}{
+		{"expose_generated_password", true},
+		{"authorized_keys.0", true},
+		{"authorized_keys.#", true},
+		// Replaced from vmSSHKeysCheck
+		{"ssh_keys.1234", false},
+		// Nested in a ForceNew block
+		{"userpass.0.login", true},
+		{"memory", false},
+		{"boot_disk.0.name", false},
+		{"disks.1234.name", false},
+		{"unknown", false},
+	}
+	for i, c := range cases {
+		if forcesnew := forcesNew(s, c.key); forcesnew != c.forcesnew {
+			t.Fatalf("Error in case %d, expected %t for '%s', got %t instead", i, c.forcesnew, c.key, forcesnew)
+		}
+	}
+}
+
+func TestGandi_deletionProtectionCheck(t *testing.T) {
+	state := map[string]string{
+		"id":                  "1",
+		"region_id":           "1",
+		"vlan_id":             "10",
+		"ip":                  "192.168.0.2",
+		"deletion_protection": "true",
+	}
+	cases := []struct {
+		config    map[string]interface{}
+		protected bool
+		err       bool
+	}{
+		// ForceNew attribute
+		{map[string]interface{}{"region_id": "1", "vlan_id": "10", "ip": "192.168.0.3", "deletion_protection": true}, true, true},
+		// Attribute replacing the ip in Update
+		{map[string]interface{}{"region_id": "2", "vlan_id": "10", "ip": "192.168.0.2", "deletion_protection": true}, true, true},
+		// Disabling the protection in the same plan
+		{map[string]interface{}{"region_id": "1", "vlan_id": "10", "ip": "192.168.0.3", "deletion_protection": false}, true, true},
+		{map[string]interface{}{"region_id": "1", "vlan_id": "10", "ip": "192.168.0.3"}, false, false},
+		{map[string]interface{}{"region_id": "1", "vlan_id": "10", "ip": "192.168.0.2", "deletion_protection": true}, true, false},
+	}
+	for i, c := range cases {
+		attributes := map[string]string{}
+		for k, v := range state {
+			attributes[k] = v
+		}
+		if !c.protected {
+			attributes["deletion_protection"] = "false"
+		}
+		is := &terraform.InstanceState{ID: "1", Attributes: attributes}
+		raw, err := config.NewRawConfig(c.config)
+		if err != nil {
+			t.Fatalf("Error in case %d, invalid config: %s", i, err)
+		}
+		_, err = resourcePrivateIP().Diff(is, terraform.NewResourceConfig(raw), nil)
+		if (err != nil) != c.err {
+			t.Fatalf("Error in case %d, expected error %t, got '%v' instead", i, c.err, err)
+		}
+	}
+}
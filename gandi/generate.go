@@ -0,0 +1,457 @@
+package gandi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PabloPie/go-gandi/client"
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/PabloPie/go-gandi/hosting/hostingv4"
+)
+
+// GenerateOptions configures Generate
+type GenerateOptions struct {
+	APIKey string
+	URL    string
+	// Region code or ID, every region is generated when empty
+	Region string
+	// Directory the configuration and the import script are written to
+	OutDir string
+}
+
+// Generate writes the configuration of every vm, disk, ip, vlan and ssh key
+// of a Gandi account to gandi.tf, and the script importing them to import.sh
+func Generate(opts GenerateOptions) error {
+	if opts.APIKey == "" {
+		return fmt.Errorf("[ERR] An API key is required")
+	}
+	gandiClient, err := client.NewClientv4(opts.URL, opts.APIKey)
+	if err != nil {
+		return err
+	}
+	// The raw client gives the interfaces of ips and the sources of disks
+	h := &providerMeta{
+		Hosting: hostingv4.Newv4Hosting(gandiClient),
+		client:  gandiClient,
+	}
+	regionid := ""
+	if opts.Region != "" {
+		region, err := resolveRegion(h, opts.Region)
+		if err != nil {
+			return err
+		}
+		regionid = region.ID
+	}
+	config, script, err := generateConfig(h, regionid)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(opts.OutDir, "gandi.tf"), []byte(config), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(opts.OutDir, "import.sh"), []byte(script), 0755)
+}
+
+// generator keeps the names given to every object so that resources can
+// reference each other
+type generator struct {
+	config bytes.Buffer
+	script bytes.Buffer
+	// Names already used, by resource type
+	used map[string]map[string]bool
+	// Address of the resource generated for each object, by ID
+	// or by name for disks
+	addresses map[string]string
+	// Interface of each ip and source of each disk, by ID, empty when
+	// the client does not allow raw API calls
+	ifaces  map[string]int
+	sources map[string]string
+}
+
+// generateConfig lists every object of the region, or of every region if
+// `regionid` is empty, and returns their configuration and import script
+//
+// The ipv6 created with each ipv4 is left out, it is attached and deleted
+// with the ipv4, c.f configurableIPs
+func generateConfig(h hosting.Hosting, regionid string) (string, string, error) {
+	g := &generator{
+		used:      map[string]map[string]bool{},
+		addresses: map[string]string{},
+	}
+	g.script.WriteString("#!/bin/sh\nset -e\n\n")
+
+	vlans, ips, disks, vms, err := listAccount(h, regionid)
+	if err != nil {
+		return "", "", err
+	}
+	// SSH keys are not tied to a region
+	sshkeys := h.ListKeys()
+	if len(sshkeys) >= inventoryPageSize {
+		return "", "", fullListError("ssh keys")
+	}
+	if g.ifaces, err = ipInterfaces(h, ips); err != nil {
+		return "", "", err
+	}
+	if g.sources, err = diskSources(h, disks); err != nil {
+		return "", "", err
+	}
+	ips = configurableIPs(ips, g.ifaces)
+
+	sort.Slice(vlans, func(i, j int) bool { return vlans[i].Name < vlans[j].Name })
+	sort.Slice(ips, func(i, j int) bool { return ips[i].IP < ips[j].IP })
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Name < disks[j].Name })
+	sort.Slice(vms, func(i, j int) bool { return vms[i].Hostname < vms[j].Hostname })
+	sort.Slice(sshkeys, func(i, j int) bool { return sshkeys[i].Name < sshkeys[j].Name })
+
+	for _, sshkey := range sshkeys {
+		g.sshKey(sshkey)
+	}
+	for _, vlan := range vlans {
+		g.vlan(vlan)
+	}
+	for _, ip := range ips {
+		if vlan, ok := ipVlan(vlans, ip); ok {
+			g.privateIP(ip, vlan)
+		} else {
+			g.ip(ip)
+		}
+	}
+	for _, disk := range disks {
+		g.disk(disk)
+	}
+	for _, vm := range vms {
+		g.vm(vm)
+	}
+	return g.config.String(), g.script.String(), nil
+}
+
+// listAccount lists every vlan, ip, disk and vm of the region, or of every
+// region if `regionid` is empty
+//
+// The lists of the hosting library are limited to a single page, with raw
+// API calls every page is listed, c.f listPages, and the vms are built from
+// the lists of disks and ips. Otherwise a full page is an error rather than
+// a configuration missing objects.
+func listAccount(h hosting.Hosting, regionid string) ([]hosting.Vlan, []hosting.IPAddress, []hosting.Disk, []hosting.VM, error) {
+	caller, ok := v4Caller(h)
+	if !ok {
+		vlans, err := h.ListVlans(vlanRegionFilter(regionid))
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		ips, err := h.ListIPs(hosting.IPFilter{RegionID: regionid})
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		disks, err := h.ListDisks(hosting.DiskFilter{RegionID: regionid})
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		vms, err := h.ListVMs(hosting.VMFilter{RegionID: regionid})
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		for name, n := range map[string]int{"vlans": len(vlans), "ips": len(ips), "disks": len(disks), "vms": len(vms)} {
+			if n >= inventoryPageSize {
+				return nil, nil, nil, nil, fullListError(name)
+			}
+		}
+		return vlans, ips, disks, vms, nil
+	}
+
+	var filter map[string]interface{}
+	if regionid != "" {
+		id, err := strconv.Atoi(regionid)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("[ERR] Invalid region ID %s", regionid)
+		}
+		filter = map[string]interface{}{"datacenter_id": id}
+	}
+	var vlans []hosting.Vlan
+	err := listPages(filter, func(options map[string]interface{}) (int, error) {
+		var response []inventoryVlan
+		if err := caller.Send("hosting.vlan.list", []interface{}{options}, &response); err != nil {
+			return 0, err
+		}
+		for _, vlan := range response {
+			vlans = append(vlans, vlan.vlan())
+		}
+		return len(response), nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	var ips []hosting.IPAddress
+	ipsbyvm := map[string][]hosting.IPAddress{}
+	err = listPages(filter, func(options map[string]interface{}) (int, error) {
+		var response []inventoryIP
+		if err := caller.Send("hosting.ip.list", []interface{}{options}, &response); err != nil {
+			return 0, err
+		}
+		for _, inventoryip := range response {
+			ip := inventoryip.ip()
+			ips = append(ips, ip)
+			ipsbyvm[ip.VM] = append(ipsbyvm[ip.VM], ip)
+		}
+		return len(response), nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	var disks []hosting.Disk
+	disksbyvm := map[string][]hosting.Disk{}
+	err = listPages(filter, func(options map[string]interface{}) (int, error) {
+		var response []inventoryDisk
+		if err := caller.Send("hosting.disk.list", []interface{}{options}, &response); err != nil {
+			return 0, err
+		}
+		for _, inventorydisk := range response {
+			disk := inventorydisk.disk()
+			disks = append(disks, disk)
+			for _, vmid := range disk.VM {
+				disksbyvm[vmid] = append(disksbyvm[vmid], disk)
+			}
+		}
+		return len(response), nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	var vms []hosting.VM
+	err = listPages(filter, func(options map[string]interface{}) (int, error) {
+		var response []inventoryVM
+		if err := caller.Send("hosting.vm.list", []interface{}{options}, &response); err != nil {
+			return 0, err
+		}
+		for _, inventoryvm := range response {
+			vm, ok := vmWithDisksAndIPs(inventoryvm.vm(), disksbyvm[strconv.Itoa(inventoryvm.ID)], ipsbyvm[strconv.Itoa(inventoryvm.ID)])
+			if !ok {
+				found, err := h.ListVMs(hosting.VMFilter{ID: strconv.Itoa(inventoryvm.ID)})
+				if err != nil {
+					return 0, err
+				}
+				if len(found) < 1 {
+					continue
+				}
+				vm = found[0]
+			}
+			vms = append(vms, vm)
+		}
+		return len(response), nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return vlans, ips, disks, vms, nil
+}
+
+func fullListError(name string) error {
+	return fmt.Errorf("[ERR] The list of %s returned %d objects or more and may be incomplete", name, inventoryPageSize)
+}
+
+// diskSources returns the disk or image each disk was created from, by
+// disk ID, from the API when the client allows it
+func diskSources(m interface{}, disks []hosting.Disk) (map[string]string, error) {
+	caller, ok := v4Caller(m)
+	if !ok {
+		return nil, nil
+	}
+	sources := map[string]string{}
+	for _, disk := range disks {
+		id, err := strconv.Atoi(disk.ID)
+		if err != nil {
+			return nil, err
+		}
+		response := struct {
+			Source int `xmlrpc:"source"`
+		}{}
+		if err := caller.Send("hosting.disk.info", []interface{}{id}, &response); err != nil {
+			return nil, err
+		}
+		if response.Source != 0 {
+			sources[disk.ID] = strconv.Itoa(response.Source)
+		}
+	}
+	return sources, nil
+}
+
+func vlanRegionFilter(regionid string) hosting.VlanFilter {
+	if regionid == "" {
+		return hosting.VlanFilter{}
+	}
+	return hosting.VlanFilter{RegionID: []string{regionid}}
+}
+
+// ipVlan returns the vlan whose subnet contains `ip`, ips do not
+// reference the vlan they were created in
+func ipVlan(vlans []hosting.Vlan, ip hosting.IPAddress) (hosting.Vlan, bool) {
+	addr := net.ParseIP(ip.IP)
+	for _, vlan := range vlans {
+		_, subnet, err := net.ParseCIDR(vlan.Subnet)
+		if err != nil || vlan.RegionID != ip.RegionID {
+			continue
+		}
+		if subnet.Contains(addr) {
+			return vlan, true
+		}
+	}
+	return hosting.Vlan{}, false
+}
+
+func (g *generator) sshKey(sshkey hosting.SSHKey) {
+	g.resource("gandi_ssh", sshkey.Name, sshkey.ID)
+	fmt.Fprintf(&g.config, "  name = %s\n", hclString(sshkey.Name))
+	fmt.Fprintf(&g.config, "  value = %s\n", hclString(strings.TrimSpace(sshkey.Value)))
+	g.config.WriteString("}\n\n")
+}
+
+func (g *generator) vlan(vlan hosting.Vlan) {
+	address := g.resource("gandi_vlan", vlan.Name, vlan.ID)
+	g.addresses["vlan:"+vlan.ID] = address
+	fmt.Fprintf(&g.config, "  name = %s\n", hclString(vlan.Name))
+	fmt.Fprintf(&g.config, "  region_id = %s\n", hclString(vlan.RegionID))
+	fmt.Fprintf(&g.config, "  subnet = %s\n", hclString(vlan.Subnet))
+	if vlan.Gateway != "" {
+		fmt.Fprintf(&g.config, "  gateway = %s\n", hclString(vlan.Gateway))
+	}
+	g.config.WriteString("}\n\n")
+}
+
+func (g *generator) privateIP(ip hosting.IPAddress, vlan hosting.Vlan) {
+	address := g.resource("gandi_private_ip", "ip_"+ip.IP, ip.ID)
+	g.addresses["ip:"+ip.ID] = address
+	fmt.Fprintf(&g.config, "  region_id = %s\n", hclString(ip.RegionID))
+	fmt.Fprintf(&g.config, "  vlan_id = %s\n", hclReference(g.addresses["vlan:"+vlan.ID], "id"))
+	fmt.Fprintf(&g.config, "  ip = %s\n", hclString(ip.IP))
+	g.config.WriteString("}\n\n")
+}
+
+func (g *generator) ip(ip hosting.IPAddress) {
+	address := g.resource("gandi_ip", "ip_"+ip.IP, ip.ID)
+	g.addresses["ip:"+ip.ID] = address
+	fmt.Fprintf(&g.config, "  region_id = %s\n", hclString(ip.RegionID))
+	fmt.Fprintf(&g.config, "  version = %d\n", ip.Version)
+	g.config.WriteString("}\n\n")
+}
+
+func (g *generator) disk(disk hosting.Disk) {
+	address := g.resource("gandi_disk", disk.Name, disk.ID)
+	g.addresses["disk:"+disk.Name] = address
+	fmt.Fprintf(&g.config, "  name = %s\n", hclString(disk.Name))
+	fmt.Fprintf(&g.config, "  region_id = %s\n", hclString(disk.RegionID))
+	fmt.Fprintf(&g.config, "  size = %d\n", disk.Size)
+	if source, ok := g.sources[disk.ID]; ok {
+		// Only used if the disk is replaced, the source of a disk is not
+		// imported
+		fmt.Fprintf(&g.config, "  src_disk_id = %s\n", hclString(source))
+		g.config.WriteString("\n  lifecycle {\n    ignore_changes = [\"src_disk_id\"]\n  }\n")
+	}
+	g.config.WriteString("}\n\n")
+}
+
+func (g *generator) vm(vm hosting.VM) {
+	g.resource("gandi_vm", vm.Hostname, vm.ID)
+	fmt.Fprintf(&g.config, "  name = %s\n", hclString(vm.Hostname))
+	fmt.Fprintf(&g.config, "  region_id = %s\n", hclString(vm.RegionID))
+	if vm.Farm != "" {
+		fmt.Fprintf(&g.config, "  farm = %s\n", hclString(vm.Farm))
+	}
+	fmt.Fprintf(&g.config, "  memory = %d\n", vm.Memory)
+	fmt.Fprintf(&g.config, "  cores = %d\n", vm.Cores)
+	// The login and the ssh keys of a vm cannot be read from Gandi, they
+	// are left to the user rather than guessed
+	g.config.WriteString("\n  # TODO: the login and ssh keys of the vm cannot be read from Gandi,\n")
+	g.config.WriteString("  # set userpass or ssh_keys before the vm is ever replaced\n")
+	g.config.WriteString("  # userpass {\n  #   login = \"\"\n  # }\n  # ssh_keys = []\n")
+	// Disk at position 0 is the boot disk
+	for i, disk := range vm.Disks {
+		block := "disks"
+		if i == 0 {
+			block = "boot_disk"
+		}
+		fmt.Fprintf(&g.config, "\n  %s {\n    name = %s\n  }\n", block, g.reference("disk:"+disk.Name, "name", disk.Name))
+	}
+	for _, ip := range configurableIPs(vm.Ips, g.ifaces) {
+		fmt.Fprintf(&g.config, "\n  ips {\n    id = %s\n  }\n", g.reference("ip:"+ip.ID, "id", ip.ID))
+	}
+	// The password and the ssh keys of a vm cannot be read from Gandi
+	g.config.WriteString("\n  lifecycle {\n    ignore_changes = [\"userpass\", \"ssh_keys\"]\n  }\n")
+	g.config.WriteString("}\n\n")
+}
+
+// resource opens the block of a resource and adds its import to the script,
+// the name of the resource is derived from `name`
+func (g *generator) resource(resourcetype, name, id string) string {
+	name = g.resourceName(resourcetype, name)
+	address := resourcetype + "." + name
+	fmt.Fprintf(&g.config, "resource %s %s {\n", hclString(resourcetype), hclString(name))
+	fmt.Fprintf(&g.script, "terraform import %s %s\n", address, shellQuote(id))
+	return address
+}
+
+// resourceName turns `name` into a valid and unique resource name
+func (g *generator) resourceName(resourcetype, name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	base := b.String()
+	if base == "" || !(base[0] >= 'a' && base[0] <= 'z' || base[0] == '_') {
+		base = strings.TrimPrefix(resourcetype, "gandi_") + "_" + base
+	}
+	if g.used[resourcetype] == nil {
+		g.used[resourcetype] = map[string]bool{}
+	}
+	unique := base
+	for i := 2; g.used[resourcetype][unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", base, i)
+	}
+	g.used[resourcetype][unique] = true
+	return unique
+}
+
+// reference returns a reference to the attribute of a generated resource,
+// or `value` itself if the object was not generated, e.g it is in
+// another region
+func (g *generator) reference(key, attribute, value string) string {
+	if address, ok := g.addresses[key]; ok {
+		return hclReference(address, attribute)
+	}
+	return hclString(value)
+}
+
+func hclReference(address, attribute string) string {
+	return fmt.Sprintf("\"${%s.%s}\"", address, attribute)
+}
+
+// hclString quotes a string, escaping interpolation sequences
+func hclString(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+		"${", "$${",
+		"%{", "%%{",
+	)
+	return `"` + r.Replace(s) + `"`
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,188 @@
+package gandi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PabloPie/go-gandi/hosting"
+)
+
+// testInventoryHosting serves fixed lists of objects, any other call panics
+type testInventoryHosting struct {
+	hosting.Hosting
+	vms   []hosting.VM
+	disks []hosting.Disk
+	ips   []hosting.IPAddress
+	vlans []hosting.Vlan
+	keys  []hosting.SSHKey
+}
+
+func (h testInventoryHosting) ListVMs(hosting.VMFilter) ([]hosting.VM, error) {
+	return h.vms, nil
+}
+
+func (h testInventoryHosting) ListDisks(hosting.DiskFilter) ([]hosting.Disk, error) {
+	return h.disks, nil
+}
+
+func (h testInventoryHosting) ListIPs(hosting.IPFilter) ([]hosting.IPAddress, error) {
+	return h.ips, nil
+}
+
+func (h testInventoryHosting) ListVlans(hosting.VlanFilter) ([]hosting.Vlan, error) {
+	return h.vlans, nil
+}
+
+func (h testInventoryHosting) ListKeys() []hosting.SSHKey {
+	return h.keys
+}
+
+func TestGandi_generateConfig(t *testing.T) {
+	bootdisk := hosting.Disk{ID: "10", Name: "web01-boot", RegionID: "1", Size: 10240}
+	datadisk := hosting.Disk{ID: "11", Name: "data", RegionID: "1", Size: 20480}
+	publicip := hosting.IPAddress{ID: "20", IP: "203.0.113.10", RegionID: "1", Version: hosting.IPv4}
+	privateip := hosting.IPAddress{ID: "21", IP: "192.168.1.2", RegionID: "1", Version: hosting.IPv4}
+	h := testInventoryHosting{
+		vms: []hosting.VM{{
+			ID: "1", Hostname: "web01", RegionID: "1", Memory: 512, Cores: 1,
+			Disks: []hosting.Disk{bootdisk, datadisk},
+			Ips:   []hosting.IPAddress{publicip, privateip},
+		}},
+		disks: []hosting.Disk{datadisk, bootdisk},
+		ips:   []hosting.IPAddress{publicip, privateip},
+		vlans: []hosting.Vlan{{ID: "30", Name: "back", RegionID: "1", Subnet: "192.168.1.0/24"}},
+		keys:  []hosting.SSHKey{{ID: "40", Name: "deploy", Value: testSSHKeyEd25519 + "\n"}},
+	}
+
+	config, script, err := generateConfig(h, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []string{
+		`resource "gandi_vm" "web01" {`,
+		"# TODO: the login and ssh keys of the vm cannot be read from Gandi",
+		"boot_disk {\n    name = \"${gandi_disk.web01-boot.name}\"",
+		"disks {\n    name = \"${gandi_disk.data.name}\"",
+		"ips {\n    id = \"${gandi_ip.ip_203_0_113_10.id}\"",
+		"ips {\n    id = \"${gandi_private_ip.ip_192_168_1_2.id}\"",
+		`vlan_id = "${gandi_vlan.back.id}"`,
+		`value = "` + testSSHKeyEd25519 + `"`,
+		`size = 20480`,
+		`ignore_changes = ["userpass", "ssh_keys"]`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(config, e) {
+			t.Fatalf("Expected %q in the configuration:\n%s", e, config)
+		}
+	}
+	for _, e := range []string{
+		"terraform import gandi_vm.web01 '1'",
+		"terraform import gandi_disk.web01-boot '10'",
+		"terraform import gandi_ip.ip_203_0_113_10 '20'",
+		"terraform import gandi_private_ip.ip_192_168_1_2 '21'",
+		"terraform import gandi_vlan.back '30'",
+		"terraform import gandi_ssh.deploy '40'",
+	} {
+		if !strings.Contains(script, e) {
+			t.Fatalf("Expected %q in the import script:\n%s", e, script)
+		}
+	}
+}
+
+func TestGandi_generateConfigPages(t *testing.T) {
+	n := 2*inventoryPageSize + 10
+	h := &testPagedHosting{vms: n, disks: n, ips: n}
+	config, script, err := generateConfig(h, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if vms := strings.Count(config, `resource "gandi_vm"`); vms != n {
+		t.Fatalf("Expected %d vms in the configuration, got %d instead", n, vms)
+	}
+	if imports := strings.Count(script, "terraform import gandi_disk."); imports != n {
+		t.Fatalf("Expected %d disks in the import script, got %d instead", n, imports)
+	}
+	if h.infos != 0 {
+		t.Fatalf("Expected the vms to be built from the lists, got %d vms described instead", h.infos)
+	}
+}
+
+func TestGandi_generateConfigFullList(t *testing.T) {
+	h := testInventoryHosting{ips: make([]hosting.IPAddress, inventoryPageSize)}
+	if _, _, err := generateConfig(h, ""); err == nil {
+		t.Fatalf("Expected an error for a full list of ips")
+	}
+}
+
+func TestGandi_generatorPlaceholders(t *testing.T) {
+	bootdisk := hosting.Disk{ID: "10", Name: "web01-boot", RegionID: "1", Size: 10240}
+	ipv4 := hosting.IPAddress{ID: "20", IP: "203.0.113.10", RegionID: "1", Version: hosting.IPv4}
+	ipv6 := hosting.IPAddress{ID: "21", IP: "2001:db8::10", RegionID: "1", Version: hosting.IPv6}
+	g := &generator{
+		used:      map[string]map[string]bool{},
+		addresses: map[string]string{},
+		ifaces:    map[string]int{"20": 5, "21": 5},
+		sources:   map[string]string{"10": "3315704"},
+	}
+	g.disk(bootdisk)
+	g.vm(hosting.VM{
+		ID: "1", Hostname: "web01", RegionID: "1", Memory: 512, Cores: 1,
+		Disks: []hosting.Disk{bootdisk},
+		Ips:   []hosting.IPAddress{ipv4, ipv6},
+	})
+	config := g.config.String()
+	for _, e := range []string{
+		`src_disk_id = "3315704"`,
+		`ignore_changes = ["src_disk_id"]`,
+		"# userpass {\n  #   login = \"\"\n  # }\n  # ssh_keys = []",
+		`ignore_changes = ["userpass", "ssh_keys"]`,
+		`id = "20"`,
+	} {
+		if !strings.Contains(config, e) {
+			t.Fatalf("Expected %q in the configuration:\n%s", e, config)
+		}
+	}
+	if strings.Contains(config, `id = "21"`) {
+		t.Fatalf("Expected no ipv6 created with the ipv4 in the configuration:\n%s", config)
+	}
+}
+
+func TestGandi_generatorResourceName(t *testing.T) {
+	g := &generator{used: map[string]map[string]bool{}}
+	cases := []struct {
+		Type     string
+		Name     string
+		Expected string
+	}{
+		{"gandi_vm", "web01", "web01"},
+		{"gandi_vm", "Web01", "web01_2"},
+		{"gandi_disk", "web01", "web01"},
+		{"gandi_vm", "web.example.com", "web_example_com"},
+		{"gandi_vm", "01web", "vm_01web"},
+		{"gandi_disk", "", "disk_"},
+	}
+
+	for i, c := range cases {
+		if name := g.resourceName(c.Type, c.Name); name != c.Expected {
+			t.Fatalf("Error in case %d, expected %s, got %s instead", i, c.Expected, name)
+		}
+	}
+}
+
+func TestGandi_hclString(t *testing.T) {
+	cases := []struct {
+		Value    string
+		Expected string
+	}{
+		{"web01", `"web01"`},
+		{`a "quoted" \ value`, `"a \"quoted\" \\ value"`},
+		{"${var.x} %{if}", `"$${var.x} %%{if}"`},
+		{"line\nbreak", `"line\nbreak"`},
+	}
+
+	for i, c := range cases {
+		if s := hclString(c.Value); s != c.Expected {
+			t.Fatalf("Error in case %d, expected %s, got %s instead", i, c.Expected, s)
+		}
+	}
+}
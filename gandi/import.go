@@ -0,0 +1,224 @@
+package gandi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// importRef is an import ID of the form [region:<code>/]<kind>:<value>,
+// e.g `name:web01`, `region:FR-SD6/name:data1` or `ip:203.0.113.10`
+//
+// Any ID without a kind is the ID of the object and is imported as is
+type importRef struct {
+	Region string
+	Kind   string
+	Value  string
+}
+
+const (
+	importKindName = "name"
+	importKindIP   = "ip"
+)
+
+func parseImportRef(id string) (importRef, error) {
+	var ref importRef
+	if strings.HasPrefix(id, "region:") {
+		parts := strings.SplitN(id, "/", 2)
+		if len(parts) < 2 {
+			return ref, fmt.Errorf("[ERR] Invalid import ID '%s', expected region:<code>/<kind>:<value>", id)
+		}
+		ref.Region = strings.TrimPrefix(parts[0], "region:")
+		id = parts[1]
+	}
+	parts := strings.SplitN(id, ":", 2)
+	if len(parts) < 2 {
+		if ref.Region != "" {
+			return ref, fmt.Errorf("[ERR] Invalid import ID '%s', a region requires a name or an ip", id)
+		}
+		ref.Value = id
+		return ref, nil
+	}
+	ref.Kind, ref.Value = parts[0], parts[1]
+	if ref.Kind != importKindName && ref.Kind != importKindIP {
+		return ref, fmt.Errorf("[ERR] Invalid import ID '%s', expected name:<name> or ip:<ip>", id)
+	}
+	if ref.Value == "" {
+		return ref, fmt.Errorf("[ERR] Invalid import ID '%s', missing %s", id, ref.Kind)
+	}
+	return ref, nil
+}
+
+func (ref importRef) String() string {
+	s := ref.Kind + ":" + ref.Value
+	if ref.Region != "" {
+		s = "region:" + ref.Region + "/" + s
+	}
+	return s
+}
+
+// importLookup returns the IDs of the objects matching `ref`, `regionid`
+// is the ID of the region of the reference, empty if there is none
+type importLookup func(h hosting.Hosting, ref importRef, regionid string) ([]string, error)
+
+// importStateByRef resolves an importRef to the ID of the object before
+// handing it to `next`, a reference must match exactly one object
+func importStateByRef(lookup importLookup, next schema.StateFunc) schema.StateFunc {
+	return func(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+		ref, err := parseImportRef(d.Id())
+		if err != nil {
+			return nil, err
+		}
+		if ref.Kind == "" {
+			return next(d, m)
+		}
+		h := m.(hosting.Hosting)
+		regionid := ""
+		if ref.Region != "" {
+			region, err := resolveRegion(h, ref.Region)
+			if err != nil {
+				return nil, err
+			}
+			regionid = region.ID
+		}
+		ids, err := lookup(h, ref, regionid)
+		if err != nil {
+			return nil, err
+		}
+		switch len(ids) {
+		case 0:
+			return nil, fmt.Errorf("[ERR] Nothing matches '%s'", ref)
+		case 1:
+			d.SetId(ids[0])
+			return next(d, m)
+		}
+		return nil, fmt.Errorf("[ERR] '%s' is ambiguous, it matches %s, import by ID or add a region",
+			ref, strings.Join(ids, ", "))
+	}
+}
+
+func unsupportedImportKind(ref importRef, objects string) error {
+	return fmt.Errorf("[ERR] %s cannot be imported by %s", objects, ref.Kind)
+}
+
+func vmImportLookup(h hosting.Hosting, ref importRef, regionid string) ([]string, error) {
+	var ids []string
+	switch ref.Kind {
+	case importKindName:
+		vms, err := h.ListVMs(hosting.VMFilter{Hostname: ref.Value, RegionID: regionid})
+		if err != nil {
+			return nil, err
+		}
+		for _, vm := range vms {
+			if vm.Hostname == ref.Value {
+				ids = append(ids, vm.ID)
+			}
+		}
+	case importKindIP:
+		ips, err := ipImportMatches(h, ref, regionid)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if ipAttached(ip) && !containsString(ids, ip.VM) {
+				ids = append(ids, ip.VM)
+			}
+		}
+	}
+	return ids, nil
+}
+
+func diskImportLookup(h hosting.Hosting, ref importRef, regionid string) ([]string, error) {
+	if ref.Kind != importKindName {
+		return nil, unsupportedImportKind(ref, "Disks")
+	}
+	disks, err := h.ListDisks(hosting.DiskFilter{Name: ref.Value, RegionID: regionid})
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, disk := range disks {
+		if disk.Name == ref.Value {
+			ids = append(ids, disk.ID)
+		}
+	}
+	return ids, nil
+}
+
+func vlanImportLookup(h hosting.Hosting, ref importRef, regionid string) ([]string, error) {
+	if ref.Kind != importKindName {
+		return nil, unsupportedImportKind(ref, "Vlans")
+	}
+	filter := hosting.VlanFilter{Name: ref.Value}
+	if regionid != "" {
+		filter.RegionID = []string{regionid}
+	}
+	vlans, err := h.ListVlans(filter)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, vlan := range vlans {
+		if vlan.Name == ref.Value {
+			ids = append(ids, vlan.ID)
+		}
+	}
+	return ids, nil
+}
+
+// SSH keys are not tied to a region and their names are unique
+func sshImportLookup(h hosting.Hosting, ref importRef, regionid string) ([]string, error) {
+	if ref.Kind != importKindName {
+		return nil, unsupportedImportKind(ref, "SSH keys")
+	}
+	if ref.Region != "" {
+		return nil, fmt.Errorf("[ERR] SSH keys have no region, import them with name:<name>")
+	}
+	sshkey := h.KeyFromName(ref.Value)
+	if sshkey.ID == "" {
+		return nil, nil
+	}
+	return []string{sshkey.ID}, nil
+}
+
+func ipImportLookup(h hosting.Hosting, ref importRef, regionid string) ([]string, error) {
+	if ref.Kind != importKindIP {
+		return nil, unsupportedImportKind(ref, "IPs")
+	}
+	ips, err := ipImportMatches(h, ref, regionid)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, ip := range ips {
+		ids = append(ids, ip.ID)
+	}
+	return ids, nil
+}
+
+// ipImportMatches lists the ips whose address is the value of `ref`,
+// private ips can share an address across vlans
+func ipImportMatches(h hosting.Hosting, ref importRef, regionid string) ([]hosting.IPAddress, error) {
+	ips, err := h.ListIPs(hosting.IPFilter{IP: ref.Value, RegionID: regionid})
+	if err != nil {
+		return nil, err
+	}
+	var matches []hosting.IPAddress
+	for _, ip := range ips {
+		if ip.IP == ref.Value {
+			matches = append(matches, ip)
+		}
+	}
+	return matches, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
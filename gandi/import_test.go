@@ -0,0 +1,72 @@
+package gandi
+
+import (
+	"testing"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGandi_parseImportRef(t *testing.T) {
+	cases := []struct {
+		ID       string
+		Expected importRef
+		Error    bool
+	}{
+		{"12345", importRef{Value: "12345"}, false},
+		{"name:web01", importRef{Kind: "name", Value: "web01"}, false},
+		{"region:FR-SD6/name:data1", importRef{Region: "FR-SD6", Kind: "name", Value: "data1"}, false},
+		{"ip:203.0.113.10", importRef{Kind: "ip", Value: "203.0.113.10"}, false},
+		{"ip:2001:db8::1", importRef{Kind: "ip", Value: "2001:db8::1"}, false},
+		{"region:LU-BI1/ip:192.168.1.2", importRef{Region: "LU-BI1", Kind: "ip", Value: "192.168.1.2"}, false},
+		{"region:FR-SD6", importRef{}, true},
+		{"region:FR-SD6/12345", importRef{}, true},
+		{"id:12345", importRef{}, true},
+		{"name:", importRef{}, true},
+	}
+
+	for i, c := range cases {
+		ref, err := parseImportRef(c.ID)
+		if (err != nil) != c.Error {
+			t.Fatalf("Error in case %d, expected error %t, got %v instead", i, c.Error, err)
+		}
+		if !c.Error && ref != c.Expected {
+			t.Fatalf("Error in case %d, expected %+v, got %+v instead", i, c.Expected, ref)
+		}
+	}
+}
+
+func TestGandi_importStateByRef(t *testing.T) {
+	lookup := func(h hosting.Hosting, ref importRef, regionid string) ([]string, error) {
+		switch ref.Value {
+		case "unique":
+			return []string{"1"}, nil
+		case "shared":
+			return []string{"2", "3"}, nil
+		}
+		return nil, nil
+	}
+	cases := []struct {
+		ID       string
+		Expected string
+		Error    bool
+	}{
+		{"42", "42", false},
+		{"name:unique", "1", false},
+		{"name:shared", "", true},
+		{"name:unknown", "", true},
+	}
+
+	importer := importStateByRef(lookup, schema.ImportStatePassthrough)
+	for i, c := range cases {
+		d := resourceDisk().TestResourceData()
+		d.SetId(c.ID)
+		states, err := importer(d, testKeyHosting{})
+		if (err != nil) != c.Error {
+			t.Fatalf("Error in case %d, expected error %t, got %v instead", i, c.Error, err)
+		}
+		if !c.Error && states[0].Id() != c.Expected {
+			t.Fatalf("Error in case %d, expected ID %s, got %s instead", i, c.Expected, states[0].Id())
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package gandi
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PabloPie/go-gandi/client"
+	"github.com/PabloPie/go-gandi/hosting"
+)
+
+// inventoryPageSize is the number of objects listed per call when a
+// snapshot is taken
+const inventoryPageSize = 100
+
+// snapshot holds every object of a type, listed once on the first lookup
+// by ID when prefetch_inventory is set
+//
+// A snapshot is not taken again once an operation modified the objects,
+// lookups are then made directly. An object missing from the snapshot is
+// also looked up directly.
+type snapshot struct {
+	mu sync.Mutex
+	// Closed once the snapshot is taken, nil until the first lookup
+	taken chan struct{}
+	stale bool
+	// Objects by ID
+	objects map[string]interface{}
+}
+
+// take takes the snapshot with `list` if needed, it returns false if the
+// objects must be looked up directly
+//
+// Lookups made while the snapshot is taken wait for it, the mutex is not
+// held during `list` and an invalidation discards the snapshot being taken
+func (s *snapshot) take(list func() (map[string]interface{}, error)) bool {
+	s.mu.Lock()
+	if s.stale {
+		s.mu.Unlock()
+		return false
+	}
+	taken := s.taken
+	if taken == nil {
+		taken = make(chan struct{})
+		s.taken = taken
+		s.mu.Unlock()
+		objects, err := list()
+		s.mu.Lock()
+		if err != nil {
+			log.Printf("[WARN] Could not prefetch inventory: %s", err)
+			s.stale = true
+		} else if !s.stale {
+			s.objects = objects
+		}
+		close(taken)
+	}
+	s.mu.Unlock()
+	<-taken
+	return true
+}
+
+// lookup returns the object with the ID given, taking the snapshot with
+// `list` if needed, ok is false if the object must be looked up directly
+func (s *snapshot) lookup(id string, list func() (map[string]interface{}, error)) (object interface{}, ok bool) {
+	if !s.take(list) {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stale {
+		return nil, false
+	}
+	object, ok = s.objects[id]
+	return
+}
+
+// filter returns the objects for which `match` is true, taking the snapshot
+// with `list` if needed, ok is false if the objects must be looked up
+// directly
+func (s *snapshot) filter(list func() (map[string]interface{}, error), match func(object interface{}) bool) (objects []interface{}, ok bool) {
+	if !s.take(list) {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stale {
+		return nil, false
+	}
+	for _, object := range s.objects {
+		if match(object) {
+			objects = append(objects, object)
+		}
+	}
+	return objects, true
+}
+
+func (s *snapshot) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stale = true
+	s.objects = nil
+}
+
+// inventory holds the snapshots of every vm, disk, ip and vlan of the
+// account, c.f cachingHosting
+type inventory struct {
+	vms   snapshot
+	disks snapshot
+	ips   snapshot
+	vlans snapshot
+}
+
+func (i *inventory) invalidate() {
+	i.vms.invalidate()
+	i.disks.invalidate()
+	i.ips.invalidate()
+	i.vlans.invalidate()
+}
+
+// listPages calls `list` with the options of each page, until a page is
+// not full, `list` returns the number of objects of its page. The options
+// also hold `filter`, which can be nil
+func listPages(filter map[string]interface{}, list func(options map[string]interface{}) (int, error)) error {
+	for page := 0; ; page++ {
+		options := map[string]interface{}{
+			"items_per_page": inventoryPageSize,
+			"page":           page,
+		}
+		for key, value := range filter {
+			options[key] = value
+		}
+		n, err := list(options)
+		if err != nil {
+			return err
+		}
+		if n < inventoryPageSize {
+			return nil
+		}
+	}
+}
+
+// Objects of the list calls of the API, vm.list does not describe the
+// disks and ips of a vm, they are taken from the snapshots of the disks
+// and ips, c.f vmFromInventory
+
+type inventoryVM struct {
+	ID          int       `xmlrpc:"id"`
+	Hostname    string    `xmlrpc:"hostname"`
+	RegionID    int       `xmlrpc:"datacenter_id"`
+	Farm        string    `xmlrpc:"farm"`
+	Description string    `xmlrpc:"description"`
+	Cores       int       `xmlrpc:"cores"`
+	Memory      int       `xmlrpc:"memory"`
+	DateCreated time.Time `xmlrpc:"date_created"`
+	State       string    `xmlrpc:"state"`
+}
+
+func (vm inventoryVM) vm() hosting.VM {
+	return hosting.VM{
+		ID:          strconv.Itoa(vm.ID),
+		Hostname:    vm.Hostname,
+		RegionID:    strconv.Itoa(vm.RegionID),
+		Farm:        vm.Farm,
+		Description: vm.Description,
+		Cores:       vm.Cores,
+		Memory:      vm.Memory,
+		DateCreated: vm.DateCreated,
+		State:       vm.State,
+	}
+}
+
+type inventoryDisk struct {
+	ID       int    `xmlrpc:"id"`
+	Name     string `xmlrpc:"name"`
+	Size     int    `xmlrpc:"size"`
+	RegionID int    `xmlrpc:"datacenter_id"`
+	State    string `xmlrpc:"state"`
+	Type     string `xmlrpc:"type"`
+	VM       []int  `xmlrpc:"vms_id"`
+	BootDisk bool   `xmlrpc:"is_boot_disk"`
+}
+
+func (disk inventoryDisk) disk() hosting.Disk {
+	var vms []string
+	for _, vm := range disk.VM {
+		vms = append(vms, strconv.Itoa(vm))
+	}
+	return hosting.Disk{
+		ID:       strconv.Itoa(disk.ID),
+		Name:     disk.Name,
+		Size:     disk.Size / 1024,
+		RegionID: strconv.Itoa(disk.RegionID),
+		State:    disk.State,
+		Type:     disk.Type,
+		VM:       vms,
+		BootDisk: disk.BootDisk,
+	}
+}
+
+type inventoryIP struct {
+	ID       int    `xmlrpc:"id"`
+	IP       string `xmlrpc:"ip"`
+	RegionID int    `xmlrpc:"datacenter_id"`
+	Version  int    `xmlrpc:"version"`
+	VM       int    `xmlrpc:"vm_id"`
+	State    string `xmlrpc:"state"`
+}
+
+func (ip inventoryIP) ip() hosting.IPAddress {
+	version := hosting.IPv4
+	if ip.Version == 6 {
+		version = hosting.IPv6
+	}
+	return hosting.IPAddress{
+		ID:       strconv.Itoa(ip.ID),
+		IP:       ip.IP,
+		RegionID: strconv.Itoa(ip.RegionID),
+		Version:  version,
+		VM:       strconv.Itoa(ip.VM),
+		State:    ip.State,
+	}
+}
+
+type inventoryVlan struct {
+	ID       int    `xmlrpc:"id"`
+	Name     string `xmlrpc:"name"`
+	Gateway  string `xmlrpc:"gateway"`
+	Subnet   string `xmlrpc:"subnet"`
+	RegionID int    `xmlrpc:"datacenter_id"`
+}
+
+func (vlan inventoryVlan) vlan() hosting.Vlan {
+	return hosting.Vlan{
+		ID:       strconv.Itoa(vlan.ID),
+		Name:     vlan.Name,
+		Gateway:  vlan.Gateway,
+		Subnet:   vlan.Subnet,
+		RegionID: strconv.Itoa(vlan.RegionID),
+	}
+}
+
+// vmFromInventory returns the vm with the ID given, ok is false if it must
+// be looked up directly
+//
+// The disks and ips of the vm are taken from their snapshots, the vm is
+// looked up directly when they are stale or when its boot disk cannot be
+// told apart. Without raw API calls, vms are always looked up directly.
+func (c *cachingHosting) vmFromInventory(id string) (hosting.VM, bool) {
+	if c.inventory == nil || id == "" {
+		return hosting.VM{}, false
+	}
+	caller, ok := c.Hosting.(client.V4Caller)
+	if !ok {
+		return hosting.VM{}, false
+	}
+	object, ok := c.inventory.vms.lookup(id, func() (map[string]interface{}, error) {
+		objects := map[string]interface{}{}
+		err := listPages(nil, func(options map[string]interface{}) (int, error) {
+			var response []inventoryVM
+			if err := caller.Send("hosting.vm.list", []interface{}{options}, &response); err != nil {
+				return 0, err
+			}
+			for _, vm := range response {
+				objects[strconv.Itoa(vm.ID)] = vm.vm()
+			}
+			return len(response), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[INFO] Prefetched %d vms", len(objects))
+		return objects, nil
+	})
+	if !ok {
+		return hosting.VM{}, false
+	}
+	vm := object.(hosting.VM)
+
+	disks, ok := c.inventory.disks.filter(c.listDiskInventory, func(object interface{}) bool {
+		for _, vmid := range object.(hosting.Disk).VM {
+			if vmid == id {
+				return true
+			}
+		}
+		return false
+	})
+	if !ok {
+		return hosting.VM{}, false
+	}
+	ips, ok := c.inventory.ips.filter(c.listIPInventory, func(object interface{}) bool {
+		return object.(hosting.IPAddress).VM == id
+	})
+	if !ok {
+		return hosting.VM{}, false
+	}
+
+	var attacheddisks []hosting.Disk
+	for _, object := range disks {
+		attacheddisks = append(attacheddisks, object.(hosting.Disk))
+	}
+	var attachedips []hosting.IPAddress
+	for _, object := range ips {
+		attachedips = append(attachedips, object.(hosting.IPAddress))
+	}
+	return vmWithDisksAndIPs(vm, attacheddisks, attachedips)
+}
+
+// vmWithDisksAndIPs returns `vm` with the disks and ips attached to it,
+// taken from the lists of disks and ips. ok is false if its boot disk
+// cannot be told apart, the vm must then be looked up directly
+func vmWithDisksAndIPs(vm hosting.VM, disks []hosting.Disk, ips []hosting.IPAddress) (hosting.VM, bool) {
+	// Disk at position 0 is the boot disk
+	var boot, data []hosting.Disk
+	for _, disk := range disks {
+		if disk.BootDisk {
+			boot = append(boot, disk)
+		} else {
+			data = append(data, disk)
+		}
+	}
+	if len(disks) > 0 && len(boot) != 1 {
+		return hosting.VM{}, false
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].ID < data[j].ID })
+	vm.Disks = append(boot, data...)
+	vm.Ips = append([]hosting.IPAddress{}, ips...)
+	sort.Slice(vm.Ips, func(i, j int) bool { return vm.Ips[i].ID < vm.Ips[j].ID })
+	return vm, true
+}
+
+func (c *cachingHosting) diskFromInventory(id string) (hosting.Disk, bool) {
+	if c.inventory == nil || id == "" {
+		return hosting.Disk{}, false
+	}
+	object, ok := c.inventory.disks.lookup(id, c.listDiskInventory)
+	if !ok {
+		return hosting.Disk{}, false
+	}
+	return object.(hosting.Disk), true
+}
+
+// listDiskInventory lists every disk of the account, c.f snapshot
+func (c *cachingHosting) listDiskInventory() (map[string]interface{}, error) {
+	objects := map[string]interface{}{}
+	caller, ok := c.Hosting.(client.V4Caller)
+	if !ok {
+		disks, err := c.Hosting.ListDisks(hosting.DiskFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, disk := range disks {
+			objects[disk.ID] = disk
+		}
+		return objects, nil
+	}
+	err := listPages(nil, func(options map[string]interface{}) (int, error) {
+		var response []inventoryDisk
+		if err := caller.Send("hosting.disk.list", []interface{}{options}, &response); err != nil {
+			return 0, err
+		}
+		for _, disk := range response {
+			objects[strconv.Itoa(disk.ID)] = disk.disk()
+		}
+		return len(response), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Prefetched %d disks", len(objects))
+	return objects, nil
+}
+
+func (c *cachingHosting) ipFromInventory(id string) (hosting.IPAddress, bool) {
+	if c.inventory == nil || id == "" {
+		return hosting.IPAddress{}, false
+	}
+	object, ok := c.inventory.ips.lookup(id, c.listIPInventory)
+	if !ok {
+		return hosting.IPAddress{}, false
+	}
+	return object.(hosting.IPAddress), true
+}
+
+// listIPInventory lists every ip of the account, c.f snapshot
+func (c *cachingHosting) listIPInventory() (map[string]interface{}, error) {
+	objects := map[string]interface{}{}
+	caller, ok := c.Hosting.(client.V4Caller)
+	if !ok {
+		ips, err := c.Hosting.ListIPs(hosting.IPFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			objects[ip.ID] = ip
+		}
+		return objects, nil
+	}
+	err := listPages(nil, func(options map[string]interface{}) (int, error) {
+		var response []inventoryIP
+		if err := caller.Send("hosting.ip.list", []interface{}{options}, &response); err != nil {
+			return 0, err
+		}
+		for _, ip := range response {
+			objects[strconv.Itoa(ip.ID)] = ip.ip()
+		}
+		return len(response), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Prefetched %d ips", len(objects))
+	return objects, nil
+}
+
+func (c *cachingHosting) vlanFromInventory(id string) (hosting.Vlan, bool) {
+	if c.inventory == nil || id == "" {
+		return hosting.Vlan{}, false
+	}
+	object, ok := c.inventory.vlans.lookup(id, func() (map[string]interface{}, error) {
+		objects := map[string]interface{}{}
+		caller, ok := c.Hosting.(client.V4Caller)
+		if !ok {
+			vlans, err := c.Hosting.ListVlans(hosting.VlanFilter{})
+			if err != nil {
+				return nil, err
+			}
+			for _, vlan := range vlans {
+				objects[vlan.ID] = vlan
+			}
+			return objects, nil
+		}
+		err := listPages(nil, func(options map[string]interface{}) (int, error) {
+			var response []inventoryVlan
+			if err := caller.Send("hosting.vlan.list", []interface{}{options}, &response); err != nil {
+				return 0, err
+			}
+			for _, vlan := range response {
+				objects[strconv.Itoa(vlan.ID)] = vlan.vlan()
+			}
+			return len(response), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[INFO] Prefetched %d vlans", len(objects))
+		return objects, nil
+	})
+	if !ok {
+		return hosting.Vlan{}, false
+	}
+	return object.(hosting.Vlan), true
+}
+
+// ListVMs is served from the inventory for lookups by ID
+func (c *cachingHosting) ListVMs(filter hosting.VMFilter) ([]hosting.VM, error) {
+	if filter == (hosting.VMFilter{ID: filter.ID}) {
+		if vm, ok := c.vmFromInventory(filter.ID); ok {
+			return []hosting.VM{vm}, nil
+		}
+	}
+	return c.Hosting.ListVMs(filter)
+}
+
+// ListVlans is served from the inventory for lookups by ID
+func (c *cachingHosting) ListVlans(filter hosting.VlanFilter) ([]hosting.Vlan, error) {
+	if len(filter.ID) == 1 && len(filter.RegionID) == 0 && filter.Name == "" {
+		if vlan, ok := c.vlanFromInventory(filter.ID[0]); ok {
+			return []hosting.Vlan{vlan}, nil
+		}
+	}
+	return c.Hosting.ListVlans(filter)
+}
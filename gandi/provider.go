@@ -1,9 +1,12 @@
 package gandi
 
 import (
-	c "github.com/PabloPie/go-gandi/client"
+	"fmt"
+
+	"github.com/PabloPie/go-gandi/client"
 	"github.com/PabloPie/go-gandi/hosting/hostingv4"
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/hashicorp/terraform/terraform"
 )
 
@@ -25,10 +28,39 @@ func Provider() terraform.ResourceProvider {
 				DefaultFunc: schema.EnvDefaultFunc("GANDI_API_URL", ""),
 				Description: "Gandi API URL to use for requests",
 			},
+			"default_region": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("GANDI_DEFAULT_REGION", ""),
+				Description: "Region code (e.g FR-SD6) or ID used by resources without region_id",
+			},
+			"prefetch_inventory": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "List every vm, disk, ip and vlan once and refresh resources from these lists",
+			},
+			"max_parallel_operations": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of disks and ips attached to or detached from a vm at the same time",
+			},
 		},
 		DataSourcesMap: map[string]*schema.Resource{
-			"gandi_region": dataSourceRegion(),
-			"gandi_image":  dataSourceImage(),
+			"gandi_region":  dataSourceRegion(),
+			"gandi_regions": dataSourceRegions(),
+			"gandi_image":   dataSourceImage(),
+			"gandi_images":  dataSourceImages(),
+			"gandi_vm":      dataSourceVM(),
+			"gandi_vms":     dataSourceVMs(),
+			"gandi_disk":    dataSourceDisk(),
+			"gandi_disks":   dataSourceDisks(),
+			"gandi_ip":      dataSourceIP(),
+			"gandi_ips":     dataSourceIPs(),
+			"gandi_vlan":    dataSourceVlan(),
+			"gandi_ssh_key": dataSourceSSHKey(),
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			"gandi_disk":       resourceDisk(),
@@ -42,9 +74,32 @@ func Provider() terraform.ResourceProvider {
 	}
 }
 
+// v4Caller returns the client used to call Gandi's API directly, for the
+// information the hosting library does not expose
+func v4Caller(m interface{}) (client.V4Caller, bool) {
+	if meta, ok := m.(*providerMeta); ok {
+		return meta.client, meta.client != nil
+	}
+	caller, ok := m.(client.V4Caller)
+	return caller, ok
+}
+
 // we need to make this variable for hosting, livedns, domain...
 func getGandiClient(d *schema.ResourceData) (interface{}, error) {
-	gandiClient, _ := c.NewClientv4(d.Get("url").(string), d.Get("api_key").(string))
+	gandiClient, _ := client.NewClientv4(d.Get("url").(string), d.Get("api_key").(string))
 	gandiHosting := hostingv4.Newv4Hosting(gandiClient)
-	return gandiHosting, nil
+	meta := &providerMeta{
+		Hosting: newCachingHosting(gandiHosting, d.Get("prefetch_inventory").(bool)),
+		client:  gandiClient,
+
+		maxParallelOperations: d.Get("max_parallel_operations").(int),
+	}
+	if region, ok := d.GetOk("default_region"); ok {
+		defaultRegion, err := resolveRegion(gandiHosting, region.(string))
+		if err != nil {
+			return nil, fmt.Errorf("[ERR] Invalid default_region '%s': %s", region, err)
+		}
+		meta.defaultRegion = defaultRegion
+	}
+	return meta, nil
 }
@@ -11,20 +11,21 @@ import (
 )
 
 func resourceDisk() *schema.Resource {
-	return &schema.Resource{
+	r := &schema.Resource{
 		Create: resourceDiskCreate,
 		Read:   resourceDiskRead,
 		Update: resourceDiskUpdate,
 		Delete: resourceDiskDelete,
 		Exists: resourceDiskExists,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importStateByRef(diskImportLookup, schema.ImportStatePassthrough),
 		},
 
 		Schema: map[string]*schema.Schema{
 			"region_id": {
 				Type:     schema.TypeString,
-				Required: true,
+				Optional: true,
+				Computed: true,
 				// XXX: until we implement DC migration
 				ForceNew: true,
 			},
@@ -74,15 +75,24 @@ func resourceDisk() *schema.Resource {
 				Type:     schema.TypeBool,
 				Computed: true,
 			},
+			"deletion_protection": deletionProtectionSchema(),
 		},
-		CustomizeDiff: sizeUpdateCheck(),
 	}
+	r.CustomizeDiff = customdiff.All(
+		sizeUpdateCheck(),
+		deletionProtectionCheck(r.Schema),
+	)
+	return r
 }
 
 func resourceDiskCreate(d *schema.ResourceData, m interface{}) error {
 	h := m.(hosting.Hosting)
+	regionid, err := regionID(d, m)
+	if err != nil {
+		return err
+	}
 	diskspec := hosting.DiskSpec{
-		RegionID: d.Get("region_id").(string),
+		RegionID: regionid,
 	}
 	if name, ok := d.GetOk("name"); ok {
 		diskspec.Name = name.(string)
@@ -93,11 +103,10 @@ func resourceDiskCreate(d *schema.ResourceData, m interface{}) error {
 	srcdisk, fromDisk := d.GetOk("src_disk_id")
 	image, fromImage := d.GetOk("image")
 	var disk hosting.Disk
-	var err error
 	if fromDisk {
 		diskimage := hosting.DiskImage{
 			DiskID:   srcdisk.(string),
-			RegionID: d.Get("region_id").(string),
+			RegionID: regionid,
 		}
 		if disk, err = h.CreateDiskFromImage(diskspec, diskimage); err != nil {
 			return err
@@ -180,6 +189,9 @@ func resourceDiskUpdate(d *schema.ResourceData, m interface{}) error {
 
 func resourceDiskDelete(d *schema.ResourceData, m interface{}) (err error) {
 	h := m.(hosting.Hosting)
+	if err = checkDeletionProtection(d); err != nil {
+		return
+	}
 	if exists, _ := resourceDiskExists(d, m); exists {
 		disk := hosting.Disk{
 			ID: d.Id(),
@@ -210,5 +222,8 @@ func sizeUpdateCheck() schema.CustomizeDiffFunc {
 			// if it is decreased
 			return new.(int) < old.(int)
 		}),
+		customdiff.IfValueChange("size", func(old, new, meta interface{}) bool {
+			return new.(int) < old.(int)
+		}, deletionProtectionCheck(nil, "size")),
 	)
 }
@@ -2,6 +2,7 @@ package gandi
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"testing"
 
@@ -86,6 +87,34 @@ func TestAccGandiDisk_withNameAndSizeFromImage(t *testing.T) {
 	})
 }
 
+func TestAccGandiDisk_deletionProtection(t *testing.T) {
+	diskname := fmt.Sprintf("gandidisk%d", acctest.RandIntRange(0, 10000))
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGandiRegion + fmt.Sprintf(testAccGandiDiskProtected, diskname, 10, true),
+				Check: resource.ComposeTestCheckFunc(
+					testCheckGandiDiskExists("gandi_disk.accTestDisk"),
+					resource.TestCheckResourceAttr("gandi_disk.accTestDisk", "deletion_protection", "true"),
+				),
+			},
+			{
+				// Shrinking a disk forces a new one
+				Config:      testAccGandiRegion + fmt.Sprintf(testAccGandiDiskProtected, diskname, 5, true),
+				ExpectError: regexp.MustCompile("deletion_protection is enabled"),
+			},
+			{
+				Config: testAccGandiRegion + fmt.Sprintf(testAccGandiDiskProtected, diskname, 10, false),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("gandi_disk.accTestDisk", "deletion_protection", "false"),
+				),
+			},
+		},
+	})
+}
+
 func testCheckGandiDiskExists(disk string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[disk]
@@ -142,3 +171,12 @@ resource "gandi_disk" "accTestDisk" {
 	region_id = "${data.gandi_region.accTestRegion.id}"
 }
 `
+
+var testAccGandiDiskProtected = `
+resource "gandi_disk" "accTestDisk" {
+	region_id = "${data.gandi_region.accTestRegion.id}"
+	name = "%s"
+	size = "%d"
+	deletion_protection = %t
+}
+`
@@ -9,21 +9,22 @@ import (
 )
 
 func resourceIP() *schema.Resource {
-	return &schema.Resource{
+	r := &schema.Resource{
 		Create: resourceIPCreate,
 		Read:   resourceIPRead,
 		Update: resourceIPUpdate,
 		Delete: resourceIPDelete,
 		Exists: resourceIPExists,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importStateByRef(ipImportLookup, schema.ImportStatePassthrough),
 		},
 
 		Schema: map[string]*schema.Schema{
 			// XXX: pending iface migration implementation
 			"region_id": {
 				Type:     schema.TypeString,
-				Required: true,
+				Optional: true,
+				Computed: true,
 			},
 			"version": {
 				Type:     schema.TypeInt,
@@ -50,14 +51,22 @@ func resourceIP() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"deletion_protection": deletionProtectionSchema(),
 		},
 	}
+	// Changing the region deletes the ip, c.f resourceIPUpdate
+	r.CustomizeDiff = deletionProtectionCheck(r.Schema, "region_id")
+	return r
 }
 
 func resourceIPCreate(d *schema.ResourceData, m interface{}) error {
 	h := m.(hosting.Hosting)
+	regionid, err := regionID(d, m)
+	if err != nil {
+		return err
+	}
 	region := hosting.Region{
-		ID: d.Get("region_id").(string),
+		ID: regionid,
 	}
 	version := d.Get("version").(int)
 	ip, err := h.CreateIP(region, hosting.IPVersion(version))
@@ -100,6 +109,9 @@ func resourceIPUpdate(d *schema.ResourceData, m interface{}) error {
 	if !d.HasChange("region_id") && !d.HasChange("version") {
 		return resourceIPRead(d, m)
 	}
+	if err := checkDeletionProtection(d); err != nil {
+		return err
+	}
 
 	var err error
 	h := m.(hosting.Hosting)
@@ -119,6 +131,9 @@ func resourceIPUpdate(d *schema.ResourceData, m interface{}) error {
 
 func resourceIPDelete(d *schema.ResourceData, m interface{}) (err error) {
 	h := m.(hosting.Hosting)
+	if err = checkDeletionProtection(d); err != nil {
+		return
+	}
 	ip := hosting.IPAddress{
 		ID: d.Id(),
 	}
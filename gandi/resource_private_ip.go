@@ -8,21 +8,22 @@ import (
 )
 
 func resourcePrivateIP() *schema.Resource {
-	return &schema.Resource{
+	r := &schema.Resource{
 		Create: resourcePrivateIPCreate,
 		Read:   resourcePrivateIPRead,
 		Update: resourcePrivateIPUpdate,
 		Delete: resourceIPDelete,
 		Exists: resourcePrivateIPExists,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importStateByRef(ipImportLookup, schema.ImportStatePassthrough),
 		},
 
 		Schema: map[string]*schema.Schema{
 			// XXX: pending iface migration implementation
 			"region_id": {
 				Type:     schema.TypeString,
-				Required: true,
+				Optional: true,
+				Computed: true,
 			},
 			"vlan_id": {
 				Type:     schema.TypeString,
@@ -43,14 +44,22 @@ func resourcePrivateIP() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"deletion_protection": deletionProtectionSchema(),
 		},
 	}
+	// Changing the region deletes the ip, c.f resourcePrivateIPUpdate
+	r.CustomizeDiff = deletionProtectionCheck(r.Schema, "region_id")
+	return r
 }
 
 func resourcePrivateIPCreate(d *schema.ResourceData, m interface{}) error {
 	h := m.(hosting.Hosting)
+	regionid, err := regionID(d, m)
+	if err != nil {
+		return err
+	}
 	region := hosting.Region{
-		ID: d.Get("region_id").(string),
+		ID: regionid,
 	}
 	vlan := hosting.Vlan{
 		ID:       d.Get("vlan_id").(string),
@@ -92,6 +101,9 @@ func resourcePrivateIPUpdate(d *schema.ResourceData, m interface{}) error {
 	if !d.HasChange("region_id") {
 		return resourceIPRead(d, m)
 	}
+	if err := checkDeletionProtection(d); err != nil {
+		return err
+	}
 
 	var err error
 	h := m.(hosting.Hosting)
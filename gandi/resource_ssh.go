@@ -1,10 +1,13 @@
 package gandi
 
 import (
+	"bytes"
 	"fmt"
-	"regexp"
+	"log"
+	"strings"
 
 	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/config/hcl2shim"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -12,23 +15,29 @@ func resourceSSH() *schema.Resource {
 	return &schema.Resource{
 		Create: resourceSSHCreate,
 		Read:   resourceSSHRead,
+		Update: resourceSSHUpdate,
 		Delete: resourceSSHDelete,
 		Exists: resourceSSHExists,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importStateByRef(sshImportLookup, schema.ImportStatePassthrough),
 		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
 				Required: true,
-				ForceNew: true,
 			},
 			"value": {
-				Type:         schema.TypeString,
-				Required:     true,
-				ForceNew:     true,
-				ValidateFunc: sshKeyValidateName,
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateFunc:     sshKeyValidateName,
+				DiffSuppressFunc: sshKeyDiffSuppress,
+			},
+			// Minimum size of RSA keys, DSA keys are rejected when set
+			"min_rsa_bits": {
+				Type:     schema.TypeInt,
+				Optional: true,
 			},
 			// Computed
 			"fingerprint": {
@@ -36,6 +45,7 @@ func resourceSSH() *schema.Resource {
 				Computed: true,
 			},
 		},
+		CustomizeDiff: sshKeyDiff,
 	}
 }
 
@@ -52,7 +62,7 @@ func resourceSSHCreate(d *schema.ResourceData, m interface{}) error {
 
 func resourceSSHRead(d *schema.ResourceData, m interface{}) error {
 	h := m.(hosting.Hosting)
-	sshkey := h.KeyFromName(d.Get("name").(string))
+	sshkey := sshKeyFromState(h, d)
 	if sshkey.ID == "" {
 		d.SetId("")
 		return nil
@@ -60,9 +70,35 @@ func resourceSSHRead(d *schema.ResourceData, m interface{}) error {
 	d.Set("name", sshkey.Name)
 	d.Set("value", sshkey.Value)
 	d.Set("fingerprint", sshkey.Fingerprint)
+	if key, err := parseSSHPublicKey(sshkey.Value); err == nil && !key.matchesFingerprint(sshkey.Fingerprint) {
+		log.Printf("[WARN] SSH key %s: fingerprint %s returned by Gandi does not match the key (%s)",
+			sshkey.Name, sshkey.Fingerprint, key.fingerprint())
+	}
 	return nil
 }
 
+// resourceSSHUpdate renames a key, there is no rename operation so a new key
+// is created with the new name before deleting the old one. VMs keep their
+// copy of the key, renaming does not affect them.
+func resourceSSHUpdate(d *schema.ResourceData, m interface{}) error {
+	h := m.(hosting.Hosting)
+	if d.HasChange("name") {
+		oldkey := hosting.SSHKey{ID: d.Id()}
+		newkey, err := h.CreateKey(d.Get("name").(string), d.Get("value").(string))
+		if err != nil {
+			return fmt.Errorf("[ERR] Could not create the renamed ssh key: %s", err)
+		}
+		if err := h.DeleteKey(oldkey); err != nil {
+			if rberr := h.DeleteKey(newkey); rberr != nil {
+				log.Printf("[WARN] Could not delete ssh key %s while rolling back: %s", newkey.ID, rberr)
+			}
+			return fmt.Errorf("[ERR] Could not delete ssh key %s after renaming it: %s", oldkey.ID, err)
+		}
+		d.SetId(newkey.ID)
+	}
+	return resourceSSHRead(d, m)
+}
+
 func resourceSSHDelete(d *schema.ResourceData, m interface{}) error {
 	h := m.(hosting.Hosting)
 	sshkey := hosting.SSHKey{ID: d.Id()}
@@ -73,14 +109,93 @@ func resourceSSHDelete(d *schema.ResourceData, m interface{}) error {
 func resourceSSHExists(d *schema.ResourceData, m interface{}) (bool, error) {
 	h := m.(hosting.Hosting)
 
-	sshkey := h.KeyFromName(d.Get("name").(string))
+	sshkey := sshKeyFromState(h, d)
 	return sshkey.ID != "", nil
 }
 
+// sshKeyFromState looks the key up by name and checks it is still the key
+// in the state, otherwise (e.g after an import) by ID
+func sshKeyFromState(h hosting.Hosting, d *schema.ResourceData) hosting.SSHKey {
+	if name := d.Get("name").(string); name != "" {
+		if sshkey := h.KeyFromName(name); sshkey.ID == d.Id() {
+			return sshkey
+		}
+	}
+	for _, sshkey := range h.ListKeys() {
+		if sshkey.ID == d.Id() {
+			return sshkey
+		}
+	}
+	return hosting.SSHKey{}
+}
+
+// sshKeyDiffSuppress compares keys by type and blob, ignoring comments
+// and whitespace, Gandi normalizes the value of the keys it stores
+func sshKeyDiffSuppress(k, old, new string, d *schema.ResourceData) bool {
+	oldkey, olderr := parseSSHPublicKey(old)
+	newkey, newerr := parseSSHPublicKey(new)
+	if olderr != nil || newerr != nil {
+		return strings.TrimSpace(old) == strings.TrimSpace(new)
+	}
+	return oldkey.Type == newkey.Type && bytes.Equal(oldkey.Blob, newkey.Blob)
+}
+
 func sshKeyValidateName(value interface{}, name string) (warnings []string, errors []error) {
-	r := regexp.MustCompile(`^(?:ssh-(?:rsa|dss|ed25519)|ecdsa-\S+) [A-Za-z0-9/+=]+(?: (\S+))?$`)
-	if !r.Match([]byte(value.(string))) {
-		errors = append(errors, fmt.Errorf("Invalid value: '%s', does not match %s", value.(string), r))
+	if _, err := parseSSHPublicKey(value.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("Invalid value for %s: %s", name, err))
 	}
 	return
 }
+
+// sshKeyDiff checks the key against min_rsa_bits and computes its fingerprint
+// so that it is shown in the plan, a fingerprint in the state that does not
+// match the key means the key stored by Gandi differs and must be recreated
+//
+// The names of the keys created or renamed are recorded for the vms that
+// reference them, c.f vmSSHKeysCheck
+func sshKeyDiff(d *schema.ResourceDiff, m interface{}) error {
+	if meta, ok := m.(*providerMeta); ok && d.NewValueKnown("name") && (d.Id() == "" || d.HasChange("name")) {
+		meta.planSSHKey(d.Get("name").(string))
+	}
+	value := d.Get("value").(string)
+	if !d.NewValueKnown("value") || value == hcl2shim.UnknownVariableValue {
+		return nil
+	}
+	key, err := parseSSHPublicKey(value)
+	if err != nil {
+		return fmt.Errorf("[ERR] Invalid ssh key: %s", err)
+	}
+	if err := checkSSHKeyStrength(key, d.Get("min_rsa_bits").(int)); err != nil {
+		return err
+	}
+
+	fingerprint, _ := d.GetChange("fingerprint")
+	if fingerprint.(string) == "" {
+		return d.SetNew("fingerprint", key.fingerprint())
+	}
+	if !key.matchesFingerprint(fingerprint.(string)) {
+		log.Printf("[WARN] SSH key %s: fingerprint %s does not match the configured key (%s), it will be recreated",
+			d.Id(), fingerprint, key.fingerprint())
+		if err := d.SetNew("fingerprint", key.fingerprint()); err != nil {
+			return err
+		}
+		return d.ForceNew("fingerprint")
+	}
+	return nil
+}
+
+func checkSSHKeyStrength(key sshPublicKey, minbits int) error {
+	if minbits <= 0 {
+		return nil
+	}
+	basetype, _ := key.baseType()
+	switch basetype {
+	case "ssh-dss":
+		return fmt.Errorf("[ERR] DSA keys are not allowed when min_rsa_bits is set")
+	case "ssh-rsa":
+		if key.Bits < minbits {
+			return fmt.Errorf("[ERR] RSA key is %d bits, min_rsa_bits requires at least %d", key.Bits, minbits)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package gandi
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/PabloPie/go-gandi/hosting"
@@ -29,6 +30,68 @@ func TestAccGandiSSH_basic(t *testing.T) {
 	})
 }
 
+func TestAccGandiSSH_rename(t *testing.T) {
+	keyname := acctest.RandomWithPrefix("gandissh")
+	keyvalue, _, _ := acctest.RandSSHKeyPair("")
+	vmname := fmt.Sprintf("gandivm-%d", acctest.RandIntRange(1, 1000))
+	keyconfig := fmt.Sprintf(testAccGandiSSHWithVM, keyname, keyvalue+"nonexistentmail", vmname)
+	renamedconfig := fmt.Sprintf(testAccGandiSSHWithVM, keyname+"-renamed", keyvalue+"othercomment", vmname)
+	var vmid string
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: keyconfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckGandiSSHExists("gandi_ssh.accTestSSH"),
+					func(s *terraform.State) error {
+						vmid = s.RootModule().Resources["gandi_vm.accTestVM"].Primary.ID
+						return nil
+					},
+				),
+			},
+			{
+				Config: renamedconfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckGandiSSHExists("gandi_ssh.accTestSSH"),
+					resource.TestCheckResourceAttr("gandi_ssh.accTestSSH", "name", keyname+"-renamed"),
+					// The vm keeps its key, it is not replaced
+					func(s *terraform.State) error {
+						if id := s.RootModule().Resources["gandi_vm.accTestVM"].Primary.ID; id != vmid {
+							return fmt.Errorf("Expected vm %s to be kept, got vm %s instead", vmid, id)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestGandi_sshKeyDiffSuppress(t *testing.T) {
+	cases := []struct {
+		Old      string
+		New      string
+		Expected bool
+	}{
+		{testSSHKeyEd25519, testSSHKeyEd25519, true},
+		{testSSHKeyEd25519, testSSHKeyEd25519 + "\n", true},
+		{testSSHKeyEd25519, strings.Replace(testSSHKeyEd25519, " ", "  ", -1), true},
+		{testSSHKeyEd25519, strings.TrimSuffix(testSSHKeyEd25519, " test") + " other@comment", true},
+		{testSSHKeyEd25519, strings.TrimSuffix(testSSHKeyEd25519, " test"), true},
+		{testSSHKeyEd25519, testSSHKeyRSA2048, false},
+		{"", testSSHKeyEd25519, false},
+		{"invalid", "invalid\n", true},
+	}
+
+	for i, c := range cases {
+		if got := sshKeyDiffSuppress("value", c.Old, c.New, nil); got != c.Expected {
+			t.Fatalf("Error in case %d, expected %t, got %t instead", i, c.Expected, got)
+		}
+	}
+}
+
 func testCheckGandiSSHExists(key string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[key]
@@ -52,3 +115,41 @@ resource "gandi_ssh" "accTestSSH" {
 	value = "%s"
 }
 `
+
+var testAccGandiSSHWithVM = `
+resource "gandi_ssh" "accTestSSH" {
+	name = "%s"
+	value = "%s"
+}
+
+data "gandi_region" "datacenter" {
+    region_code = "FR-SD6"
+}
+
+data "gandi_image" "debian9" {
+  name = "Debian 9"
+  region_id = "${data.gandi_region.datacenter.id}"
+}
+
+resource "gandi_ip" "ip1" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  version = 6
+}
+
+resource "gandi_disk" "systemdisk1" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  src_disk_id = "${data.gandi_image.debian9.disk_id}"
+}
+
+resource "gandi_vm" "accTestVM" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  name = "%s"
+  ips {
+    id = "${gandi_ip.ip1.id}"
+  }
+  boot_disk {
+    name = "${gandi_disk.systemdisk1.name}"
+  }
+  ssh_keys = ["${gandi_ssh.accTestSSH.name}"]
+}
+`
@@ -15,13 +15,14 @@ func resourceVlan() *schema.Resource {
 		Delete: resourceVlanDelete,
 		Exists: resourceVlanExists,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importStateByRef(vlanImportLookup, schema.ImportStatePassthrough),
 		},
 
 		Schema: map[string]*schema.Schema{
 			"region_id": {
 				Type:     schema.TypeString,
-				Required: true,
+				Optional: true,
+				Computed: true,
 				ForceNew: true,
 			},
 			"name": {
@@ -45,8 +46,12 @@ func resourceVlan() *schema.Resource {
 
 func resourceVlanCreate(d *schema.ResourceData, m interface{}) error {
 	h := m.(hosting.Hosting)
+	regionid, err := regionID(d, m)
+	if err != nil {
+		return err
+	}
 	vlanspec := hosting.VlanSpec{
-		RegionID: d.Get("region_id").(string),
+		RegionID: regionid,
 	}
 	if name, ok := d.GetOk("name"); ok {
 		vlanspec.Name = name.(string)
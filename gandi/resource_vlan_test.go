@@ -0,0 +1,62 @@
+package gandi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/acctest"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccGandiVlan_basic(t *testing.T) {
+	vlanname := fmt.Sprintf("gandivlan%d", acctest.RandIntRange(0, 10000))
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGandiRegion + fmt.Sprintf(testAccGandiVlanBasic, vlanname),
+				Check: resource.ComposeTestCheckFunc(
+					testCheckGandiVlanExists("gandi_vlan.accTestVlan"),
+					resource.TestCheckResourceAttr("gandi_vlan.accTestVlan", "name", vlanname),
+					resource.TestCheckResourceAttr("gandi_private_ip.accTestPrivateIP", "ip", "192.168.100.10"),
+				),
+			},
+		},
+	})
+}
+
+func testCheckGandiVlanExists(vlan string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[vlan]
+		if !ok {
+			return fmt.Errorf("Not found: %s", vlan)
+		}
+		vlanid := rs.Primary.ID
+		h := testAccProvider.Meta().(hosting.Hosting)
+		vlans, err := h.ListVlans(hosting.VlanFilter{ID: []string{vlanid}})
+		if err != nil {
+			return err
+		}
+		if len(vlans) < 1 {
+			return fmt.Errorf("Error: Vlan %q does not exist", vlanid)
+		}
+		return nil
+	}
+}
+
+var testAccGandiVlanBasic = `
+resource "gandi_vlan" "accTestVlan" {
+	region_id = "${data.gandi_region.accTestRegion.id}"
+	name = "%s"
+	subnet = "192.168.100.0/24"
+}
+
+resource "gandi_private_ip" "accTestPrivateIP" {
+	region_id = "${data.gandi_region.accTestRegion.id}"
+	vlan_id = "${gandi_vlan.accTestVlan.id}"
+	ip = "192.168.100.10"
+}
+`
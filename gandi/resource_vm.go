@@ -4,27 +4,39 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"time"
 
 	"github.com/PabloPie/go-gandi/hosting"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/helper/customdiff"
+	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 )
 
 func resourceVM() *schema.Resource {
-	return &schema.Resource{
+	r := &schema.Resource{
 		Create: resourceVMCreate,
 		Read:   resourceVMRead,
 		Update: resourceVMUpdate,
 		Delete: resourceVMDelete,
 		Exists: resourceVMExists,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importStateByRef(vmImportLookup, resourceVMImportState),
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
 		},
 
 		Schema: map[string]*schema.Schema{
 			// VM
 			"region_id": {
 				Type:     schema.TypeString,
-				Required: true,
+				Optional: true,
+				Computed: true,
 			},
 			"name": {
 				Type:     schema.TypeString,
@@ -56,34 +68,77 @@ func resourceVM() *schema.Resource {
 			// Auth
 			// keys and login can change on boot, c.f vm.start()
 			// Modification requires stopping and starting the machine
+			// Only changes of the keys themselves replace the vm, c.f vmSSHKeysCheck
 			"ssh_keys": {
 				Type:     schema.TypeSet,
 				Optional: true,
-				ForceNew: true,
 				Elem: &schema.Schema{
 					Type: schema.TypeString,
 				},
-				Description: "Names of the ssh keys allowed to connect",
+				Description: "Names, IDs or fingerprints of the ssh keys allowed to connect",
 			},
-			"userpass": {
+			"ssh_key_fingerprints": {
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: "SHA256 fingerprints of the keys of ssh_keys the vm was created with",
+			},
+			"authorized_keys": {
 				Type:     schema.TypeList,
-				MaxItems: 1,
 				Optional: true,
 				ForceNew: true,
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					ValidateFunc:     sshKeyValidateName,
+					DiffSuppressFunc: sshKeyDiffSuppress,
+				},
+				Description: "Public keys allowed to connect, uploaded as gandi keys managed by the vm",
+			},
+			"managed_key_ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"userpass": {
+				Type:             schema.TypeList,
+				MaxItems:         1,
+				Optional:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: userpassDiffSuppress,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"login": {
 							Type:     schema.TypeString,
 							Required: true,
 						},
+						// Generated if not provided, only its hash is stored
 						"password": {
-							Type:      schema.TypeString,
-							Required:  true,
-							Sensitive: true,
+							Type:             schema.TypeString,
+							Optional:         true,
+							Sensitive:        true,
+							ValidateFunc:     vmPasswordValidate,
+							DiffSuppressFunc: passwordDiffSuppress,
 						},
 					},
 				},
 			},
+			"expose_generated_password": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+				Description: "Store the generated password in generated_password, it stays in the state in plain text for as long as the vm exists",
+			},
+			"generated_password": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "Password generated at creation when expose_generated_password is true, kept in the state in plain text",
+			},
 			"boot_disk": {
 				Type:     schema.TypeList,
 				Required: true,
@@ -143,13 +198,84 @@ func resourceVM() *schema.Resource {
 					},
 				},
 			},
+			// Connection
+			"connection_user": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "root",
+				Description: "User for provisioners to connect with when no userpass is provided",
+			},
+			"public_ipv4": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"public_ipv6": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			// Power management
 			"state": {
 				Type:     schema.TypeString,
 				Computed: true,
-				Optional: true,
 			},
+			"desired_state": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "running",
+				ValidateFunc: validation.StringInSlice([]string{"running", "halted"}, false),
+				Description:  "State the vm must be in, running or halted",
+			},
+			"allow_stop_for_update": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Allow stopping the vm when a memory or cores update cannot be done while running",
+			},
+			"reboot_triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Arbitrary values, changing any of them reboots the vm",
+			},
+			"delete_old_boot_disk": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Delete the previous boot disk after it has been replaced",
+			},
+			// Destroy behaviour
+			"delete_boot_disk_on_destroy": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Delete the boot disk when the vm is destroyed, even if a gandi_disk with deletion_protection manages it",
+			},
+			"delete_data_disks_on_destroy": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Delete every data disk when the vm is destroyed, even if a gandi_disk with deletion_protection manages it",
+			},
+			"release_ips_on_destroy": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Delete every ip attached when the vm is destroyed, even if a gandi_ip with deletion_protection manages it",
+			},
+			"imported": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "The vm was imported, its userpass and ssh_keys are not known",
+			},
+			"deletion_protection": deletionProtectionSchema(),
 		},
 	}
+	r.CustomizeDiff = customdiff.All(
+		deletionProtectionCheck(r.Schema),
+		vmLimitsCheck,
+		vmRegionCheck,
+		vmSSHKeysCheck,
+	)
+	return r
 }
 
 func resourceVMCreate(d *schema.ResourceData, m interface{}) error {
@@ -160,48 +286,82 @@ func resourceVMCreate(d *schema.ResourceData, m interface{}) error {
 	if err != nil {
 		return err
 	}
+	if vmspec.RegionID, err = regionID(d, m); err != nil {
+		return err
+	}
+	sshkeys, err := resolveSSHKeys(h, vmspec.SSHKeysID)
+	if err != nil {
+		return err
+	}
+	vmspec.SSHKeysID = sshKeyNames(sshkeys)
 	ipslist := d.Get("ips").(*schema.Set).List()
-	ips, err := parseIPS(h, ipslist)
+	ips, err := parseIPS(h, vmspec.RegionID, ipslist)
 	if err != nil {
 		return err
 	}
 	bootdiskraw := d.Get("boot_disk").([]interface{})
-	bootdisk := parseDisks(h, bootdiskraw)
+	bootdisk := parseDisks(h, vmspec.RegionID, bootdiskraw)
+	if err != nil {
+		return err
+	}
+
+	managedkeys, err := uploadAuthorizedKeys(h, d.Get("authorized_keys").([]interface{}))
 	if err != nil {
 		return err
 	}
+	var managedkeyids []string
+	for _, sshkey := range managedkeys {
+		vmspec.SSHKeysID = append(vmspec.SSHKeysID, sshkey.Name)
+		managedkeyids = append(managedkeyids, sshkey.ID)
+	}
 
 	vm, _, _, err = h.CreateVMWithExistingDiskAndIP(vmspec, ips[0], bootdisk[0])
 	if err != nil {
+		if rberr := deleteManagedKeys(h, managedkeys); rberr != nil {
+			log.Printf("[WARN] Could not delete uploaded keys while rolling back: %s", rberr)
+		}
 		return err
 	}
 
-	// Attach remaining ips, the first one was attached on creation
-	for _, ip := range ips[1:] {
-		log.Printf("[INFO] Attaching ip '%s' to vm '%s'...", ip.IP, vm.Hostname)
-		vm, ip, err := h.AttachIP(vm, ip)
-		if err != nil {
-			log.Printf("[WARN] Error attaching ip '%s' to vm '%s': %s", ip.ID, vm.Hostname, err)
+	d.SetId(vm.ID)
+	// ssh_keys is kept as configured, the vm only knows the names of its keys
+	d.Set("ssh_key_fingerprints", sshKeyFingerprints(sshkeys))
+	d.Set("managed_key_ids", managedkeyids)
+	if vmspec.Login != "" {
+		if d.Get("userpass.0.password").(string) == "" && d.Get("expose_generated_password").(bool) {
+			d.Set("generated_password", vmspec.Password)
+		}
+		if err := setUserpass(d, vmspec.Login, vmspec.Password); err != nil {
+			return err
 		}
 	}
 
 	disklist := d.Get("disks").(*schema.Set).List()
-	disks := parseDisks(h, disklist)
-	if err != nil {
-		return err
-	}
+	disks := parseDisks(h, vmspec.RegionID, disklist)
 
-	// Attach non-boot disks, the final disk list will not contain the boot disk
+	// Attach remaining ips, the first one was attached on creation, and
+	// non-boot disks, the final disk list will not contain the boot disk
+	var attach []vmOperation
+	for _, ip := range ips[1:] {
+		attach = append(attach, attachIPOperation(h, vm, ip))
+	}
 	for _, disk := range disks {
-		log.Printf("[INFO] Attaching disk '%s' to vm '%s'...", disk.Name, vm.Hostname)
-		vm, disk, err := h.AttachDisk(vm, disk)
-		if err != nil {
-			log.Printf("[WARN] Error attaching disk '%s' to vm '%s': %s", disk.Name, vm.Hostname, err)
+		attach = append(attach, attachDiskOperation(h, vm, disk))
+	}
+	if err := runVMOperations(maxParallelOperations(m), attach); err != nil {
+		// The vm is kept in the state, tainted, with the disks and ips
+		// that could be attached
+		if rerr := resourceVMRead(d, m); rerr != nil {
+			log.Printf("[WARN] Could not read vm '%s': %s", vm.ID, rerr)
 		}
+		return err
 	}
 
-	d.SetId(vm.ID)
-	d.Set("ssh_keys", vm.SSHKeys)
+	if d.Get("desired_state").(string) == "halted" {
+		if err := setVMPowerState(h, vm, "halted", d.Timeout(schema.TimeoutCreate)); err != nil {
+			return err
+		}
+	}
 	return resourceVMRead(d, m)
 }
 
@@ -225,15 +385,26 @@ func resourceVMRead(d *schema.ResourceData, m interface{}) error {
 	}
 	d.Set("cores", vm.Cores)
 	d.Set("state", vm.State)
+	// States written before passwords were hashed contain the password
+	if password := d.Get("userpass.0.password").(string); password != "" && !isPasswordHash(password) {
+		if err := setUserpass(d, d.Get("userpass.0.login").(string), password); err != nil {
+			return err
+		}
+	}
+	// Transitional states are ignored, a vm stopped or started outside
+	// of terraform is brought back to its desired state
+	if vm.State == "running" || vm.State == "halted" {
+		d.Set("desired_state", vm.State)
+	}
 
 	// Creating an ipv4 creates also an ipv6, to avoid adding
 	// to the state an unasked IP we check which ips were attached
-	// by the user
+	// by the user, every ip is kept when none is known (on import)
 	// XXX: this is temporary and is a bad workaround
 	askedips := d.Get("ips").(*schema.Set).List()
 	var ips []map[string]interface{}
 	for _, ip := range vm.Ips {
-		if !containsIP(askedips, ip) {
+		if len(askedips) > 0 && !containsIP(askedips, ip) {
 			continue
 		}
 		ips = append(
@@ -257,22 +428,112 @@ func resourceVMRead(d *schema.ResourceData, m interface{}) error {
 	}
 	d.Set("ips", ips)
 	// Disk at position 0 is the boot disk
-	d.Set("disks", disks[1:])
-	d.Set("boot_disk", disks[:1])
+	if len(disks) > 0 {
+		d.Set("disks", disks[1:])
+		d.Set("boot_disk", disks[:1])
+	} else {
+		d.Set("disks", nil)
+		d.Set("boot_disk", nil)
+	}
+
+	// Every ip of the vm is considered, including the ipv6
+	// created with an ipv4
+	publicipv4, publicipv6 := publicIPs(vm.Ips)
+	d.Set("public_ipv4", publicipv4)
+	d.Set("public_ipv6", publicipv6)
+	host := publicipv4
+	if host == "" {
+		host = publicipv6
+	}
+	user := d.Get("connection_user").(string)
+	if login, ok := d.GetOk("userpass.0.login"); ok {
+		user = login.(string)
+	}
+	d.SetConnInfo(map[string]string{
+		"type": "ssh",
+		"host": host,
+		"user": user,
+	})
 	return nil
 }
 
-func resourceVMUpdate(d *schema.ResourceData, m interface{}) error {
+// setUserpass stores the login of the vm and the hash of its password
+func setUserpass(d *schema.ResourceData, login, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return d.Set("userpass", []interface{}{
+		map[string]interface{}{
+			"login":    login,
+			"password": hash,
+		},
+	})
+}
+
+// publicIPs returns the first public ipv4 and ipv6 of a list of ips,
+// ips from a vlan are private
+func publicIPs(ips []hosting.IPAddress) (ipv4 string, ipv6 string) {
+	for _, ip := range ips {
+		addr := net.ParseIP(ip.IP)
+		if addr == nil || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			continue
+		}
+		if addr.To4() != nil {
+			if ipv4 == "" {
+				ipv4 = ip.IP
+			}
+		} else if ipv6 == "" {
+			ipv6 = ip.IP
+		}
+	}
+	return
+}
+
+func resourceVMUpdate(d *schema.ResourceData, m interface{}) (err error) {
 	h := m.(hosting.Hosting)
 	vm := hosting.VM{ID: d.Id(), RegionID: d.Get("region_id").(string)}
 	d.Partial(true)
+	// the vm is restarted when it has to be stopped for an update
+	restarted := false
+	// A vm stopped to be resized is started again if any later step fails,
+	// until the boot disk swap or the power state step takes over
+	stopped := false
+	defer func() {
+		if err != nil && d.Get("desired_state").(string) == "running" {
+			err = restartOnError(h, vm, stopped, err, d.Timeout(schema.TimeoutUpdate))
+		}
+	}()
+	if d.HasChange("memory") || d.HasChange("cores") {
+		oldmem, newmem := d.GetChange("memory")
+		oldcores, newcores := d.GetChange("cores")
+		maxmem, err := vmMaxMemory(m, vm.ID)
+		if err != nil {
+			log.Printf("[WARN] Could not get max memory of vm '%s': %s", vm.ID, err)
+		}
+		needshalt := d.Get("state").(string) == "running" &&
+			vmResizeNeedsHalt(oldmem.(int), newmem.(int), maxmem, oldcores.(int), newcores.(int))
+		if needshalt {
+			if !d.Get("allow_stop_for_update").(bool) {
+				return fmt.Errorf("[ERR] Changing memory from %d to %d MB and cores from %d to %d "+
+					"requires stopping vm '%s', set allow_stop_for_update to allow it",
+					oldmem.(int), newmem.(int), oldcores.(int), newcores.(int), vm.ID)
+			}
+			log.Printf("[INFO] Stopping vm '%s' to update its memory and cores", vm.ID)
+			if err := stopVM(h, vm, d.Timeout(schema.TimeoutUpdate)); err != nil {
+				return err
+			}
+			restarted = true
+			stopped = true
+		}
+	}
 	if d.HasChange("memory") {
 		_, newmem := d.GetChange("memory")
 		vmupdated, err := h.UpdateVMMemory(vm, newmem.(int))
 		if err != nil {
 			return fmt.Errorf("[ERR] Memory update failed: %s", err)
 		}
-		log.Printf("[INFO] Memory for vm '%s' updated to %dGB", vmupdated.Hostname, vmupdated.Memory)
+		log.Printf("[INFO] Memory for vm '%s' updated to %dMB", vmupdated.Hostname, vmupdated.Memory)
 		d.SetPartial("memory")
 		vm = vmupdated
 	}
@@ -286,26 +547,13 @@ func resourceVMUpdate(d *schema.ResourceData, m interface{}) error {
 		d.SetPartial("cores")
 		vm = vmupdated
 	}
-	if d.HasChange("state") {
-		_, newstate := d.GetChange("state")
-		state := newstate.(string)
-		var err error
-		switch state {
-		case "halted":
-			err = h.StopVM(vm)
-		case "running":
-			err = h.StartVM(vm)
-		case "deleted":
-			err = resourceVMDelete(d, m)
-		default:
-			return fmt.Errorf("[WARN] Invalid option for state '%s'", state)
-		}
-		if err != nil {
-			return fmt.Errorf("[ERR] Operation on VM failed: %s", err)
+	// Only the references of the keys can change in place
+	if d.HasChange("ssh_keys") {
+		if err := updateSSHKeys(d, h); err != nil {
+			return err
 		}
-		log.Printf("[INFO] Operation on VM successful")
-		d.SetPartial("state")
-		vm.State = state
+		d.SetPartial("ssh_keys")
+		d.SetPartial("ssh_key_fingerprints")
 	}
 	if d.HasChange("name") {
 		_, newname := d.GetChange("name")
@@ -317,92 +565,359 @@ func resourceVMUpdate(d *schema.ResourceData, m interface{}) error {
 		d.SetPartial("name")
 		vm = vmupdated
 	}
+	// the vm is also stopped when its boot disk is replaced, and started
+	// again only if it must be running
 	if d.HasChange("boot_disk") {
 		oldbootdisk, newbootdisk := d.GetChange("boot_disk")
-		olddisk := parseDisks(h, oldbootdisk.([]interface{}))
-		newdisk := parseDisks(h, newbootdisk.([]interface{}))
-		h.StopVM(vm)
-		// Attaching to position 0 still leaves the other disk attached
-		vmupdated, _, err := h.AttachDiskAtPosition(vm, newdisk[0], 0)
-		if err != nil {
-			return err
+		olddisk := parseDisks(h, d.Get("region_id").(string), oldbootdisk.([]interface{}))
+		newdisk := parseDisks(h, d.Get("region_id").(string), newbootdisk.([]interface{}))
+		if len(olddisk) < 1 || len(newdisk) < 1 {
+			return fmt.Errorf("[ERR] Boot disk '%s' not found", newbootdisk.([]interface{})[0].(map[string]interface{})["name"])
 		}
-		vmupdated, _, err = h.DetachDisk(vmupdated, olddisk[0])
-		if err != nil {
+		timeout := d.Timeout(schema.TimeoutUpdate)
+		// swapBootDisk starts the vm again, or leaves it halted when the
+		// original disk layout could not be restored
+		stopped = false
+		if err := swapBootDisk(h, vm, olddisk[0], newdisk[0], d.Get("desired_state").(string), timeout); err != nil {
 			return err
 		}
-		h.StartVM(vm)
 		d.SetPartial("boot_disk")
+		restarted = true
+		if d.Get("delete_old_boot_disk").(bool) {
+			if err := deleteDisks(h, olddisk); err != nil {
+				return err
+			}
+		}
 	}
+	// Disks, then ips, are detached before any is attached. Each list is
+	// saved in the state once updated, the disks are kept if an ip fails.
+	// When an operation fails, the disks or ips attached are saved instead.
+	parallel := maxParallelOperations(m)
 	if d.HasChange("disks") {
+		var detach, attach []vmOperation
 		olddisks, newdisks := d.GetChange("disks")
-		olddisklist := parseDisks(h, olddisks.(*schema.Set).List())
-		newdisklist := parseDisks(h, newdisks.(*schema.Set).List())
+		olddisklist := parseDisks(h, d.Get("region_id").(string), olddisks.(*schema.Set).List())
+		newdisklist := parseDisks(h, d.Get("region_id").(string), newdisks.(*schema.Set).List())
 		todetach, toattach := diskDiff(olddisklist, newdisklist)
 		for _, disk := range todetach {
-			vmupdated, _, err := h.DetachDisk(vm, disk)
-			if err != nil {
-				return fmt.Errorf("[ERR] Could not detach Disk '%s': %s", disk.Name, err)
-			}
-			vm = vmupdated
+			detach = append(detach, detachDiskOperation(h, vm, disk))
 		}
 		for _, disk := range toattach {
-			vmupdated, _, err := h.AttachDisk(vm, disk)
-			if err != nil {
-				return fmt.Errorf("[ERR] Could not attach Disk '%s': %s", disk.Name, err)
-			}
-			vm = vmupdated
+			attach = append(attach, attachDiskOperation(h, vm, disk))
+		}
+		if err := runVMOperations(parallel, detach); err != nil {
+			return keepAttachedDisks(d, h, vm, err)
+		}
+		if err := runVMOperations(parallel, attach); err != nil {
+			return keepAttachedDisks(d, h, vm, err)
 		}
-		d.SetPartial("disks")
 	}
+	d.SetPartial("disks")
 	if d.HasChange("ips") {
+		var detach, attach []vmOperation
 		oldips, newips := d.GetChange("ips")
-		oldiplist, err := parseIPS(h, oldips.(*schema.Set).List())
+		oldiplist, err := parseIPS(h, d.Get("region_id").(string), oldips.(*schema.Set).List())
 		if err != nil {
 			return err
 		}
-		newiplist, err := parseIPS(h, newips.(*schema.Set).List())
+		newiplist, err := parseIPS(h, d.Get("region_id").(string), newips.(*schema.Set).List())
 		if err != nil {
 			return err
 		}
 		todetach, toattach := ipDiff(oldiplist, newiplist)
 		for _, ip := range todetach {
-			vmupdated, ip, err := h.DetachIP(vm, ip)
-			if err != nil {
-				return fmt.Errorf("[ERR] Could not detach IP '%s': %s", ip.IP, err)
-			}
-			vm = vmupdated
+			detach = append(detach, detachIPOperation(h, vm, ip))
 		}
 		for _, ip := range toattach {
-			vmupdated, _, err := h.AttachIP(vm, ip)
-			if err != nil {
-				return fmt.Errorf("[ERR] Could not attach IP '%s': %s", ip.IP, err)
-			}
-			vm = vmupdated
+			attach = append(attach, attachIPOperation(h, vm, ip))
+		}
+		if err := runVMOperations(parallel, detach); err != nil {
+			return keepAttachedIPs(d, h, vm, append(oldiplist, newiplist...), err)
+		}
+		if err := runVMOperations(parallel, attach); err != nil {
+			return keepAttachedIPs(d, h, vm, append(oldiplist, newiplist...), err)
+		}
+	}
+	d.SetPartial("ips")
+	desiredstate := d.Get("desired_state").(string)
+	if d.HasChange("desired_state") || restarted {
+		stopped = false
+		if err := setVMPowerState(h, vm, desiredstate, d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return err
+		}
+		d.SetPartial("desired_state")
+	} else if d.HasChange("reboot_triggers") && desiredstate == "running" {
+		log.Printf("[INFO] Reboot triggers changed, rebooting vm '%s'...", vm.ID)
+		if err := h.RebootVM(vm); err != nil {
+			return fmt.Errorf("[ERR] Could not reboot vm '%s': %s", vm.ID, err)
+		}
+		if err := waitForVMState(h, vm, "running", d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return err
 		}
-		d.SetPartial("ips")
 	}
+	d.SetPartial("reboot_triggers")
 	d.Partial(false)
 	return resourceVMRead(d, m)
 }
 
-// Deleting a vm does not delete its boot disk nor any of its ips
+// keepAttachedDisks saves in the state the data disks attached to the vm
+// after some of them could not be attached or detached, so that the next
+// apply only retries the operations that failed
+func keepAttachedDisks(d *schema.ResourceData, h hosting.Hosting, vm hosting.VM, err error) error {
+	vms, lerr := h.ListVMs(hosting.VMFilter{ID: vm.ID})
+	if lerr != nil || len(vms) < 1 {
+		log.Printf("[WARN] Could not read the disks attached to vm '%s': %v", vm.ID, lerr)
+		return err
+	}
+	var disks []map[string]interface{}
+	// Disk at position 0 is the boot disk
+	for i, disk := range vms[0].Disks {
+		if i == 0 {
+			continue
+		}
+		disks = append(disks, map[string]interface{}{
+			"id":   disk.ID,
+			"name": disk.Name,
+			"size": disk.Size,
+		})
+	}
+	d.Set("disks", disks)
+	d.SetPartial("disks")
+	return err
+}
+
+// keepAttachedIPs saves in the state the ips of `known` attached to the vm
+// after some of them could not be attached or detached, c.f keepAttachedDisks
+func keepAttachedIPs(d *schema.ResourceData, h hosting.Hosting, vm hosting.VM, known []hosting.IPAddress, err error) error {
+	vms, lerr := h.ListVMs(hosting.VMFilter{ID: vm.ID})
+	if lerr != nil || len(vms) < 1 {
+		log.Printf("[WARN] Could not read the ips attached to vm '%s': %v", vm.ID, lerr)
+		return err
+	}
+	var ips []map[string]interface{}
+	for _, ip := range vms[0].Ips {
+		for _, knownip := range known {
+			if ip.ID == knownip.ID {
+				ips = append(ips, map[string]interface{}{
+					"id": ip.ID,
+					"ip": ip.IP,
+				})
+				break
+			}
+		}
+	}
+	d.Set("ips", ips)
+	d.SetPartial("ips")
+	return err
+}
+
+// swapBootDisk replaces the boot disk of a vm, the vm is stopped during
+// the operation and started again when `state`, its desired state, is
+// running
+//
+// If any step fails the original disk layout is restored
+func swapBootDisk(h hosting.Hosting, vm hosting.VM, olddisk, newdisk hosting.Disk, state string, timeout time.Duration) error {
+	log.Printf("[INFO] Replacing boot disk '%s' of vm '%s' with '%s'", olddisk.Name, vm.ID, newdisk.Name)
+	if err := setVMPowerState(h, vm, "halted", timeout); err != nil {
+		return err
+	}
+	err := func() error {
+		// Attaching to position 0 still leaves the other disk attached
+		vmupdated, _, err := h.AttachDiskAtPosition(vm, newdisk, 0)
+		if err != nil {
+			return fmt.Errorf("[ERR] Could not attach disk '%s': %s", newdisk.Name, err)
+		}
+		vms, err := h.ListVMs(hosting.VMFilter{ID: vm.ID})
+		if err != nil {
+			return err
+		}
+		if len(vms) < 1 || len(vms[0].Disks) < 1 || vms[0].Disks[0].ID != newdisk.ID {
+			return fmt.Errorf("[ERR] Disk '%s' is not the boot disk of vm '%s' after being attached", newdisk.Name, vm.ID)
+		}
+		if _, _, err = h.DetachDisk(vmupdated, olddisk); err != nil {
+			return fmt.Errorf("[ERR] Could not detach disk '%s': %s", olddisk.Name, err)
+		}
+		if state != "running" {
+			return nil
+		}
+		return startVM(h, vm, timeout)
+	}()
+	if err == nil {
+		log.Printf("[INFO] Boot disk of vm '%s' replaced", vm.ID)
+		return nil
+	}
+	log.Printf("[WARN] Boot disk replacement failed, restoring disk '%s' as boot disk: %s", olddisk.Name, err)
+	if rerr := restoreBootDisk(h, vm, olddisk, newdisk, state, timeout); rerr != nil {
+		return multierror.Append(err, rerr)
+	}
+	return err
+}
+
+// restoreBootDisk brings a vm back to its original disk layout, with
+// `olddisk` as boot disk and `newdisk` detached, and starts it when `state`
+// is running
+func restoreBootDisk(h hosting.Hosting, vm hosting.VM, olddisk, newdisk hosting.Disk, state string, timeout time.Duration) error {
+	vms, err := h.ListVMs(hosting.VMFilter{ID: vm.ID})
+	if err != nil {
+		return err
+	}
+	if len(vms) < 1 {
+		return fmt.Errorf("[ERR] VM '%s' not found", vm.ID)
+	}
+	if vms[0].State != "halted" {
+		if err := stopVM(h, vm, timeout); err != nil {
+			return err
+		}
+	}
+	// The boot disk at position 0 must be detached before the original
+	// one can take its place
+	if diskPosition(vms[0], newdisk) >= 0 {
+		if _, _, err := h.DetachDisk(vm, newdisk); err != nil {
+			return fmt.Errorf("[ERR] Could not detach disk '%s': %s", newdisk.Name, err)
+		}
+		if vms, err = h.ListVMs(hosting.VMFilter{ID: vm.ID}); err != nil {
+			return err
+		}
+		if len(vms) < 1 {
+			return fmt.Errorf("[ERR] VM '%s' not found", vm.ID)
+		}
+	}
+	switch diskPosition(vms[0], olddisk) {
+	case 0:
+	case -1:
+		if _, _, err := h.AttachDiskAtPosition(vm, olddisk, 0); err != nil {
+			return fmt.Errorf("[ERR] Could not reattach disk '%s': %s", olddisk.Name, err)
+		}
+	default:
+		if _, _, err := h.DetachDisk(vm, olddisk); err != nil {
+			return fmt.Errorf("[ERR] Could not detach disk '%s': %s", olddisk.Name, err)
+		}
+		if _, _, err := h.AttachDiskAtPosition(vm, olddisk, 0); err != nil {
+			return fmt.Errorf("[ERR] Could not reattach disk '%s': %s", olddisk.Name, err)
+		}
+	}
+	if state != "running" {
+		return nil
+	}
+	return startVM(h, vm, timeout)
+}
+
+// diskPosition returns the position of `disk` in the disks of `vm`,
+// -1 if it is not attached
+func diskPosition(vm hosting.VM, disk hosting.Disk) int {
+	for i, attached := range vm.Disks {
+		if attached.ID == disk.ID {
+			return i
+		}
+	}
+	return -1
+}
+
+// stopVM stops a vm and waits until it is halted
+func stopVM(h hosting.Hosting, vm hosting.VM, timeout time.Duration) error {
+	if err := h.StopVM(vm); err != nil {
+		return fmt.Errorf("[ERR] Could not stop vm '%s': %s", vm.ID, err)
+	}
+	return waitForVMState(h, vm, "halted", timeout)
+}
+
+// startVM starts a vm and waits until it is running
+func startVM(h hosting.Hosting, vm hosting.VM, timeout time.Duration) error {
+	if err := h.StartVM(vm); err != nil {
+		return fmt.Errorf("[ERR] Could not start vm '%s': %s", vm.ID, err)
+	}
+	return waitForVMState(h, vm, "running", timeout)
+}
+
+// setVMPowerState stops or starts a vm if it is not already in `state`
+func setVMPowerState(h hosting.Hosting, vm hosting.VM, state string, timeout time.Duration) error {
+	vms, err := h.ListVMs(hosting.VMFilter{ID: vm.ID})
+	if err != nil {
+		return err
+	}
+	if len(vms) < 1 {
+		return fmt.Errorf("[ERR] VM '%s' not found", vm.ID)
+	}
+	if vms[0].State == state {
+		return nil
+	}
+	log.Printf("[INFO] Changing state of vm '%s' from %s to %s", vm.ID, vms[0].State, state)
+	switch state {
+	case "halted":
+		return stopVM(h, vm, timeout)
+	case "running":
+		return startVM(h, vm, timeout)
+	}
+	return fmt.Errorf("[WARN] Invalid option for state '%s'", state)
+}
+
+// vmStates are the states a vm can be in, c.f hosting.VM
+var vmStates = []string{"paused", "running", "halted", "locked", "being_created", "being_migrated"}
+
+// waitForVMState polls a vm until it reaches `state`
+func waitForVMState(h hosting.Hosting, vm hosting.VM, state string, timeout time.Duration) error {
+	var pending []string
+	for _, s := range vmStates {
+		if s != state {
+			pending = append(pending, s)
+		}
+	}
+	conf := &resource.StateChangeConf{
+		Pending: pending,
+		Target:  []string{state},
+		Refresh: func() (interface{}, string, error) {
+			vms, err := h.ListVMs(hosting.VMFilter{ID: vm.ID})
+			if err != nil || len(vms) < 1 {
+				return nil, "", err
+			}
+			return vms[0], vms[0].State, nil
+		},
+		Timeout:    timeout,
+		MinTimeout: 2 * time.Second,
+	}
+	if _, err := conf.WaitForState(); err != nil {
+		return fmt.Errorf("[ERR] Error waiting for vm '%s' to be %s: %s", vm.ID, state, err)
+	}
+	return nil
+}
+
+// Deleting a vm does not delete its boot disk nor any of its ips,
+// unless asked to with the *_on_destroy options
 func resourceVMDelete(d *schema.ResourceData, m interface{}) error {
 	h := m.(hosting.Hosting)
 	vm := hosting.VM{ID: d.Id(), RegionID: d.Get("region_id").(string)}
 	var err error
+	if err = checkDeletionProtection(d); err != nil {
+		return err
+	}
 	if exists, _ := resourceVMExists(d, m); !exists {
 		return nil
 	}
-	h.StopVM(vm)
+	if err := setVMPowerState(h, vm, "halted", d.Timeout(schema.TimeoutDelete)); err != nil {
+		return err
+	}
 	// detach ips and disks to avoid deletion
-	bootdisk := d.Get("boot_disk").([]interface{})[0].(map[string]interface{})
-	disk := h.DiskFromName(bootdisk["name"].(string))
-	_, _, err = h.DetachDisk(vm, disk)
-	if err != nil {
-		return fmt.Errorf("[ERR] Could not detach disk '%s': %s", bootdisk["name"].(string), err)
+	var bootdisks []hosting.Disk
+	for _, raw := range d.Get("boot_disk").([]interface{}) {
+		name := raw.(map[string]interface{})["name"].(string)
+		disk := h.DiskFromName(name)
+		if disk.ID == "" {
+			log.Printf("[WARN] Boot disk '%s' of vm '%s' not found, it is not detached", name, vm.ID)
+			continue
+		}
+		if _, _, err = h.DetachDisk(vm, disk); err != nil {
+			return fmt.Errorf("[ERR] Could not detach disk '%s': %s", name, err)
+		}
+		bootdisks = append(bootdisks, disk)
+	}
+	disks := parseDisks(h, d.Get("region_id").(string), d.Get("disks").(*schema.Set).List())
+	for _, datadisk := range disks {
+		if _, _, err = h.DetachDisk(vm, datadisk); err != nil {
+			return fmt.Errorf("[ERR] Could not detach disk '%s': %s", datadisk.Name, err)
+		}
 	}
 	iplist := d.Get("ips").(*schema.Set).List()
+	var ips []hosting.IPAddress
 	for _, ipraw := range iplist {
 		ipmap := ipraw.(map[string]interface{})
 		ipid := ipmap["id"].(string)
@@ -411,11 +926,75 @@ func resourceVMDelete(d *schema.ResourceData, m interface{}) error {
 		if err != nil {
 			return fmt.Errorf("[ERR] Could not detach IP '%s'(%s): %s", ip.IP, ipid, err)
 		}
+		ips = append(ips, ip)
 	}
 	if err := h.DeleteVM(vm); err != nil {
 		return err
 	}
-	return nil
+	log.Printf("[INFO] VM '%s' deleted", vm.ID)
+
+	// Once the vm is gone every disk and ip is detached and can be deleted,
+	// we try to delete everything that was asked and report every failure.
+	// The deletion_protection of the resources managing them is not
+	// visible from here, each deletion is warned about instead
+	var errs *multierror.Error
+	var cascade []hosting.Disk
+	if d.Get("delete_boot_disk_on_destroy").(bool) {
+		cascade = append(cascade, bootdisks...)
+	}
+	if d.Get("delete_data_disks_on_destroy").(bool) {
+		cascade = append(cascade, disks...)
+	}
+	for _, disk := range cascade {
+		log.Printf("[WARN] Deleting disk '%s' with vm '%s', deletion_protection of a gandi_disk managing it is not checked", disk.Name, vm.ID)
+	}
+	errs = multierror.Append(errs, deleteDisks(h, cascade))
+	if d.Get("release_ips_on_destroy").(bool) {
+		for _, ip := range ips {
+			log.Printf("[WARN] Deleting IP '%s' with vm '%s', deletion_protection of a gandi_ip managing it is not checked", ip.ID, vm.ID)
+		}
+		errs = multierror.Append(errs, deleteIPs(h, ips))
+	}
+	var managedkeys []hosting.SSHKey
+	for _, id := range d.Get("managed_key_ids").([]interface{}) {
+		managedkeys = append(managedkeys, hosting.SSHKey{ID: id.(string)})
+	}
+	errs = multierror.Append(errs, deleteManagedKeys(h, managedkeys))
+	return errs.ErrorOrNil()
+}
+
+// deleteDisks deletes every disk given, logging each deletion
+func deleteDisks(h hosting.Hosting, disks []hosting.Disk) error {
+	var errs *multierror.Error
+	for _, disk := range disks {
+		if err := h.DeleteDisk(disk); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("[ERR] Could not delete disk '%s': %s", disk.Name, err))
+			continue
+		}
+		log.Printf("[INFO] Disk '%s'(%s) deleted", disk.Name, disk.ID)
+	}
+	return errs.ErrorOrNil()
+}
+
+// deleteIPs deletes every ip given, logging each deletion
+//
+// Deleting an ipv4 also deletes the ipv6 sharing its interface, an ip
+// that no longer exists is not considered an error
+func deleteIPs(h hosting.Hosting, ips []hosting.IPAddress) error {
+	var errs *multierror.Error
+	for _, ip := range ips {
+		found, err := h.ListIPs(hosting.IPFilter{ID: ip.ID})
+		if err == nil && len(found) < 1 {
+			log.Printf("[INFO] IP '%s' already deleted", ip.ID)
+			continue
+		}
+		if err := h.DeleteIP(ip); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("[ERR] Could not delete IP '%s': %s", ip.ID, err))
+			continue
+		}
+		log.Printf("[INFO] IP '%s' deleted", ip.ID)
+	}
+	return errs.ErrorOrNil()
 }
 
 func resourceVMExists(d *schema.ResourceData, m interface{}) (bool, error) {
@@ -424,8 +1003,9 @@ func resourceVMExists(d *schema.ResourceData, m interface{}) (bool, error) {
 	return (err == nil && len(vms) > 0), err
 }
 
+// The region of the vm is not set, c.f regionID, and SSHKeysID contains
+// the keys as configured, c.f resolveSSHKeys
 func parseVMSpec(d *schema.ResourceData) (vmspec hosting.VMSpec, err error) {
-	vmspec.RegionID = d.Get("region_id").(string)
 	if name, ok := d.GetOk("name"); ok {
 		vmspec.Hostname = name.(string)
 	}
@@ -449,25 +1029,45 @@ func parseVMSpec(d *schema.ResourceData) (vmspec hosting.VMSpec, err error) {
 		userpass := userpasslist[0].(map[string]interface{})
 		vmspec.Login = userpass["login"].(string)
 		vmspec.Password = userpass["password"].(string)
+		if vmspec.Password == "" {
+			if vmspec.Password, err = generatePassword(); err != nil {
+				return
+			}
+		}
 	}
-	if len(vmspec.SSHKeysID) < 1 && vmspec.Login == "" {
-		err = errors.New("SSH keys or login/password required but not provided")
+	authorizedkeys := d.Get("authorized_keys").([]interface{})
+	if len(vmspec.SSHKeysID) < 1 && len(authorizedkeys) < 1 && vmspec.Login == "" {
+		err = errors.New("SSH keys, authorized keys or login/password required but not provided")
 	}
 	return
 }
 
-func parseIPS(h hosting.Hosting, iplist []interface{}) (ips []hosting.IPAddress, err error) {
+// parseIPS looks the ips of the list up, with a single list call when
+// the hosting supports it, c.f cachingHosting
+func parseIPS(h hosting.Hosting, regionid string, iplist []interface{}) (ips []hosting.IPAddress, err error) {
+	var ids []string
 	for _, rawip := range iplist {
 		ipmap := rawip.(map[string]interface{})
-		ip, err := h.ListIPs(hosting.IPFilter{ID: ipmap["id"].(string)})
-		if err != nil {
+		ids = append(ids, ipmap["id"].(string))
+	}
+	if b, ok := batchLookupOf(h); ok {
+		if ips, err = b.ipsByID(regionid, ids); err != nil {
 			return nil, err
 		}
-		if len(ip) < 1 {
-			log.Printf("[ERR] IP with id %s not found", ipmap["id"])
-			continue
+	} else {
+		for _, id := range ids {
+			ip, err := h.ListIPs(hosting.IPFilter{ID: id})
+			if err != nil {
+				return nil, err
+			}
+			if len(ip) < 1 {
+				continue
+			}
+			ips = append(ips, ip[0])
 		}
-		ips = append(ips, ip[0])
+	}
+	if len(ips) < len(ids) {
+		log.Printf("[ERR] Only %d of the ips %v were found", len(ips), ids)
 	}
 	// at least 1 ip was provided but none are actually valid
 	if len(ips) < 1 {
@@ -476,10 +1076,23 @@ func parseIPS(h hosting.Hosting, iplist []interface{}) (ips []hosting.IPAddress,
 	return
 }
 
-func parseDisks(h hosting.Hosting, disklist []interface{}) (disks []hosting.Disk) {
+// parseDisks looks the disks of the list up, with a single list call when
+// the hosting supports it, c.f cachingHosting
+func parseDisks(h hosting.Hosting, regionid string, disklist []interface{}) (disks []hosting.Disk) {
+	var names []string
 	for _, rawdisk := range disklist {
 		diskmap := rawdisk.(map[string]interface{})
-		disk := h.DiskFromName(diskmap["name"].(string))
+		names = append(names, diskmap["name"].(string))
+	}
+	if b, ok := batchLookupOf(h); ok {
+		found, err := b.disksByName(regionid, names)
+		if err == nil {
+			return found
+		}
+		log.Printf("[WARN] Could not list the disks of region %s: %s", regionid, err)
+	}
+	for _, name := range names {
+		disk := h.DiskFromName(name)
 		if disk.ID != "" {
 			disks = append(disks, disk)
 		}
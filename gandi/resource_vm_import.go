@@ -0,0 +1,113 @@
+package gandi
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// resourceVMImportState rebuilds the configuration of a vm from the live
+// vm, the ips imported are the ones a configuration references, c.f
+// configurableIPs
+//
+// The password and the ssh keys of a vm cannot be read from Gandi, the vm
+// is marked as imported and the userpass and ssh_keys of the configuration
+// are taken as they are, c.f userpassDiffSuppress and vmSSHKeysCheck
+func resourceVMImportState(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	h := m.(hosting.Hosting)
+	vms, err := h.ListVMs(hosting.VMFilter{ID: d.Id()})
+	if err != nil {
+		return nil, err
+	}
+	if len(vms) < 1 {
+		return nil, fmt.Errorf("[ERR] VM %s not found", d.Id())
+	}
+	vm := vms[0]
+
+	// Options only known to terraform get their default value, as they
+	// would for a vm created from a configuration that omits them
+	for key, s := range resourceVM().Schema {
+		if s.Default != nil {
+			d.Set(key, s.Default)
+		}
+	}
+	d.Set("imported", true)
+	d.Set("name", vm.Hostname)
+	d.Set("region_id", vm.RegionID)
+	d.Set("memory", vm.Memory)
+	d.Set("cores", vm.Cores)
+	if vm.Farm != "" {
+		d.Set("farm", vm.Farm)
+	}
+	ifaces, err := ipInterfaces(m, vm.Ips)
+	if err != nil {
+		return nil, err
+	}
+	// resourceVMRead keeps the ips present in the state, they must
+	// be set here for the ips of the vm to be read
+	var ips []map[string]interface{}
+	for _, ip := range configurableIPs(vm.Ips, ifaces) {
+		ips = append(ips, map[string]interface{}{
+			"id": ip.ID,
+			"ip": ip.IP,
+		})
+	}
+	d.Set("ips", ips)
+	return []*schema.ResourceData{d}, nil
+}
+
+// ipInterfaces returns the interface of each ip, by ip ID, from the API
+// when the client allows it
+func ipInterfaces(m interface{}, ips []hosting.IPAddress) (map[string]int, error) {
+	caller, ok := v4Caller(m)
+	if !ok {
+		return nil, nil
+	}
+	ifaces := map[string]int{}
+	for _, ip := range ips {
+		id, err := strconv.Atoi(ip.ID)
+		if err != nil {
+			return nil, err
+		}
+		response := struct {
+			Iface int `xmlrpc:"iface_id"`
+		}{}
+		if err := caller.Send("hosting.ip.info", []interface{}{id}, &response); err != nil {
+			return nil, err
+		}
+		ifaces[ip.ID] = response.Iface
+	}
+	return ifaces, nil
+}
+
+// configurableIPs drops the ipv6 created with each ipv4, which shares the
+// interface of the ipv4. A configuration only references the ipv4, the
+// ipv6 is attached and deleted with it
+func configurableIPs(ips []hosting.IPAddress, ifaces map[string]int) []hosting.IPAddress {
+	withipv4 := map[int]bool{}
+	for _, ip := range ips {
+		if iface, ok := ifaces[ip.ID]; ok && ip.Version == hosting.IPv4 {
+			withipv4[iface] = true
+		}
+	}
+	var configurable []hosting.IPAddress
+	for _, ip := range ips {
+		if iface, ok := ifaces[ip.ID]; ok && ip.Version == hosting.IPv6 && withipv4[iface] {
+			continue
+		}
+		configurable = append(configurable, ip)
+	}
+	return configurable
+}
+
+// userpassDiffSuppress ignores the userpass of the configuration of an
+// imported vm, its login and password cannot be read from Gandi
+func userpassDiffSuppress(k, old, new string, d *schema.ResourceData) bool {
+	if !d.Get("imported").(bool) {
+		return false
+	}
+	oldlogin, _ := d.GetChange("userpass.0.login")
+	return oldlogin.(string) == ""
+}
@@ -0,0 +1,79 @@
+package gandi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestGandi_configurableIPs(t *testing.T) {
+	ipv4 := hosting.IPAddress{ID: "1", Version: hosting.IPv4}
+	ipv6 := hosting.IPAddress{ID: "2", Version: hosting.IPv6}
+	other := hosting.IPAddress{ID: "3", Version: hosting.IPv6}
+	cases := []struct {
+		ips      []hosting.IPAddress
+		ifaces   map[string]int
+		expected []hosting.IPAddress
+	}{
+		// ipv6 created with the ipv4
+		{[]hosting.IPAddress{ipv4, ipv6, other}, map[string]int{"1": 10, "2": 10, "3": 11}, []hosting.IPAddress{ipv4, other}},
+		{[]hosting.IPAddress{ipv6, other}, map[string]int{"2": 10, "3": 11}, []hosting.IPAddress{ipv6, other}},
+		// Unknown interfaces
+		{[]hosting.IPAddress{ipv4, ipv6}, nil, []hosting.IPAddress{ipv4, ipv6}},
+	}
+	for i, c := range cases {
+		if ips := configurableIPs(c.ips, c.ifaces); !reflect.DeepEqual(ips, c.expected) {
+			t.Fatalf("Error in case %d, expected %v, got %v instead", i, c.expected, ips)
+		}
+	}
+}
+
+func TestGandi_userpassDiffSuppress(t *testing.T) {
+	raw, err := config.NewRawConfig(map[string]interface{}{
+		"name": "web01",
+		"userpass": []interface{}{
+			map[string]interface{}{"login": "admin", "password": "Passwordfortest123!"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		imported    string
+		requiresnew bool
+	}{
+		{"true", false},
+		// Created without userpass
+		{"false", true},
+	}
+	for i, c := range cases {
+		is := &terraform.InstanceState{ID: "1", Attributes: map[string]string{
+			"id":       "1",
+			"name":     "web01",
+			"imported": c.imported,
+		}}
+		// As set by resourceVMImportState
+		for key, s := range resourceVM().Schema {
+			if s.Default != nil {
+				is.Attributes[key] = fmt.Sprint(s.Default)
+			}
+		}
+		diff, err := resourceVM().Diff(is, terraform.NewResourceConfig(raw), testKeyHosting{})
+		if err != nil {
+			t.Fatalf("Error in case %d: %s", i, err)
+		}
+		if diff.RequiresNew() != c.requiresnew {
+			t.Fatalf("Error in case %d, expected replacement %t, got %t instead", i, c.requiresnew, diff.RequiresNew())
+		}
+		for key := range diff.Attributes {
+			if !c.requiresnew && strings.HasPrefix(key, "userpass") {
+				t.Fatalf("Error in case %d, expected no userpass diff, got %s instead", i, key)
+			}
+		}
+	}
+}
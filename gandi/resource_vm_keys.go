@@ -0,0 +1,282 @@
+package gandi
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/config/hcl2shim"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Prefix of the names of the keys uploaded from authorized_keys
+const managedKeyPrefix = "terraform-vm-key-"
+
+// sshKeyNotFoundError is returned by resolveSSHKey when no key matches
+type sshKeyNotFoundError string
+
+func (ref sshKeyNotFoundError) Error() string {
+	return fmt.Sprintf("SSH key '%s' not found, expected the name, ID or fingerprint of an existing key", string(ref))
+}
+
+// resolveSSHKey finds the key referenced by `ref`, which can be the name,
+// the ID or the fingerprint (MD5 or SHA256) of the key
+func resolveSSHKey(h hosting.Hosting, ref string) (hosting.SSHKey, error) {
+	if sshkey := h.KeyFromName(ref); sshkey.ID != "" {
+		return sshkey, nil
+	}
+	var matches []hosting.SSHKey
+	for _, sshkey := range h.ListKeys() {
+		if sshkey.ID == ref {
+			return sshkey, nil
+		}
+		if sshKeyMatchesFingerprint(sshkey, ref) {
+			matches = append(matches, sshkey)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return hosting.SSHKey{}, sshKeyNotFoundError(ref)
+	case 1:
+		return matches[0], nil
+	}
+	var names []string
+	for _, sshkey := range matches {
+		names = append(names, sshkey.Name)
+	}
+	return hosting.SSHKey{}, fmt.Errorf("SSH key fingerprint '%s' matches several keys: %s", ref, strings.Join(names, ", "))
+}
+
+// sshKeyMatchesFingerprint compares the key with a fingerprint in any of
+// the formats accepted by matchesFingerprint
+func sshKeyMatchesFingerprint(sshkey hosting.SSHKey, fingerprint string) bool {
+	if key, err := parseSSHPublicKey(sshkey.Value); err == nil {
+		return key.matchesFingerprint(fingerprint)
+	}
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Replace(strings.TrimPrefix(s, "MD5:"), ":", "", -1))
+	}
+	return sshkey.Fingerprint != "" && normalize(sshkey.Fingerprint) == normalize(fingerprint)
+}
+
+// resolveSSHKeys returns the keys referenced
+func resolveSSHKeys(h hosting.Hosting, refs []string) ([]hosting.SSHKey, error) {
+	var sshkeys []hosting.SSHKey
+	var errs *multierror.Error
+	for _, ref := range refs {
+		sshkey, err := resolveSSHKey(h, ref)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		sshkeys = append(sshkeys, sshkey)
+	}
+	return sshkeys, errs.ErrorOrNil()
+}
+
+// sshKeyNames returns the names of keys, the API copies keys into a vm
+// by name
+func sshKeyNames(sshkeys []hosting.SSHKey) []string {
+	var names []string
+	for _, sshkey := range sshkeys {
+		names = append(names, sshkey.Name)
+	}
+	return names
+}
+
+// sshKeyFingerprints returns the SHA256 fingerprints of keys, or the one
+// computed by Gandi for the values that cannot be parsed
+func sshKeyFingerprints(sshkeys []hosting.SSHKey) []string {
+	var fingerprints []string
+	for _, sshkey := range sshkeys {
+		if key, err := parseSSHPublicKey(sshkey.Value); err == nil {
+			fingerprints = append(fingerprints, key.fingerprintSHA256())
+		} else {
+			fingerprints = append(fingerprints, sshkey.Fingerprint)
+		}
+	}
+	return fingerprints
+}
+
+// sshKeysReplaceVM tells whether ssh_keys changing to `refs` changes the
+// keys of a vm created with the keys of fingerprints `old`, a vm only gets
+// its keys on creation
+//
+// References not found, e.g the new name of a key renamed in the same
+// apply, are taken as the old keys not matched by another reference,
+// resourceVMUpdate verifies them once they exist
+func sshKeysReplaceVM(h hosting.Hosting, old []string, refs []string) bool {
+	remaining := map[string]bool{}
+	for _, fingerprint := range old {
+		remaining[fingerprint] = true
+	}
+	matched := map[string]bool{}
+	unresolved := 0
+	for _, ref := range refs {
+		if ref == "" || ref == hcl2shim.UnknownVariableValue {
+			unresolved++
+			continue
+		}
+		sshkey, err := resolveSSHKey(h, ref)
+		if err != nil {
+			unresolved++
+			continue
+		}
+		fingerprint := sshKeyFingerprints([]hosting.SSHKey{sshkey})[0]
+		if matched[fingerprint] {
+			continue
+		}
+		if !remaining[fingerprint] {
+			return true
+		}
+		delete(remaining, fingerprint)
+		matched[fingerprint] = true
+	}
+	return unresolved != len(remaining)
+}
+
+// vmSSHKeysCheck verifies at plan time that the keys in ssh_keys can be
+// found, c.f checkSSHKeyRefs
+//
+// The vm is replaced when its keys change, changing how a key is referenced,
+// or the name of a key, keeps the vm
+func vmSSHKeysCheck(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() != "" && !d.HasChange("ssh_keys") {
+		return nil
+	}
+	if oldrefs, newrefs := d.GetChange("ssh_keys"); d.Id() != "" && oldrefs.(*schema.Set).Equal(newrefs) {
+		return nil
+	}
+	if !d.NewValueKnown("ssh_keys") {
+		if d.Id() != "" {
+			return deletionProtectionForceNew(d, "ssh_keys")
+		}
+		return nil
+	}
+	h := meta.(hosting.Hosting)
+	refs := stringList(d.Get("ssh_keys").(*schema.Set).List())
+	if err := checkSSHKeyRefs(h, meta, refs); err != nil {
+		return err
+	}
+	if d.Id() == "" {
+		return nil
+	}
+
+	old := stringList(d.Get("ssh_key_fingerprints").(*schema.Set).List())
+	oldrefs, _ := d.GetChange("ssh_keys")
+	if len(old) == 0 && oldrefs.(*schema.Set).Len() == 0 && d.Get("imported").(bool) {
+		// The keys of an imported vm are not known, the ones configured
+		// are taken as they are
+		return d.SetNewComputed("ssh_key_fingerprints")
+	}
+	if len(old) == 0 {
+		// Vms created before the fingerprints were stored
+		oldkeys, err := resolveSSHKeys(h, stringList(oldrefs.(*schema.Set).List()))
+		if err != nil {
+			return deletionProtectionForceNew(d, "ssh_keys")
+		}
+		old = sshKeyFingerprints(oldkeys)
+	}
+	if sshKeysReplaceVM(h, old, refs) {
+		return deletionProtectionForceNew(d, "ssh_keys")
+	}
+	return d.SetNewComputed("ssh_key_fingerprints")
+}
+
+// checkSSHKeyRefs returns an error for every reference that matches no
+// key, except the values not known yet and the names of the keys gandi_ssh
+// creates or renames in the same apply
+func checkSSHKeyRefs(h hosting.Hosting, meta interface{}, refs []string) error {
+	var errs *multierror.Error
+	for _, ref := range refs {
+		if ref == "" || ref == hcl2shim.UnknownVariableValue {
+			continue
+		}
+		_, err := resolveSSHKey(h, ref)
+		if _, notfound := err.(sshKeyNotFoundError); notfound && sshKeyPlanned(meta, ref) {
+			continue
+		}
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func stringList(raw []interface{}) []string {
+	var values []string
+	for _, value := range raw {
+		s, _ := value.(string)
+		values = append(values, s)
+	}
+	return values
+}
+
+// updateSSHKeys stores the new references of the keys of a vm, after
+// verifying they are the keys the vm was created with, c.f vmSSHKeysCheck
+func updateSSHKeys(d *schema.ResourceData, h hosting.Hosting) error {
+	sshkeys, err := resolveSSHKeys(h, stringList(d.Get("ssh_keys").(*schema.Set).List()))
+	if err != nil {
+		return err
+	}
+	fingerprints := sshKeyFingerprints(sshkeys)
+	old := stringList(d.Get("ssh_key_fingerprints").(*schema.Set).List())
+	if len(old) > 0 && !sameStrings(old, fingerprints) {
+		return fmt.Errorf("[ERR] ssh_keys of vm '%s' reference other keys than the ones it was created with, "+
+			"the vm has to be replaced to change its keys", d.Id())
+	}
+	return d.Set("ssh_key_fingerprints", fingerprints)
+}
+
+// sameStrings tells whether a and b contain the same strings, in any order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := map[string]int{}
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		if count[s] == 0 {
+			return false
+		}
+		count[s]--
+	}
+	return true
+}
+
+// uploadAuthorizedKeys creates a gandi key for each public key given, the
+// keys created are deleted if any upload fails
+func uploadAuthorizedKeys(h hosting.Hosting, values []interface{}) ([]hosting.SSHKey, error) {
+	var sshkeys []hosting.SSHKey
+	for _, value := range values {
+		sshkey, err := h.CreateKey(resource.PrefixedUniqueId(managedKeyPrefix), value.(string))
+		if err != nil {
+			if rberr := deleteManagedKeys(h, sshkeys); rberr != nil {
+				log.Printf("[WARN] Could not delete uploaded keys while rolling back: %s", rberr)
+			}
+			return nil, fmt.Errorf("[ERR] Could not upload authorized key: %s", err)
+		}
+		log.Printf("[INFO] Authorized key uploaded as '%s'(%s)", sshkey.Name, sshkey.ID)
+		sshkeys = append(sshkeys, sshkey)
+	}
+	return sshkeys, nil
+}
+
+// deleteManagedKeys deletes the keys uploaded from authorized_keys, a vm
+// keeps its copy of the keys
+func deleteManagedKeys(h hosting.Hosting, sshkeys []hosting.SSHKey) error {
+	var errs *multierror.Error
+	for _, sshkey := range sshkeys {
+		if err := h.DeleteKey(sshkey); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("[ERR] Could not delete ssh key '%s': %s", sshkey.ID, err))
+			continue
+		}
+		log.Printf("[INFO] SSH key '%s' deleted", sshkey.ID)
+	}
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,128 @@
+package gandi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/config/hcl2shim"
+)
+
+// testKeyHosting serves a fixed list of keys, any other call panics
+type testKeyHosting struct {
+	hosting.Hosting
+	keys []hosting.SSHKey
+}
+
+func (h testKeyHosting) KeyFromName(name string) hosting.SSHKey {
+	for _, key := range h.keys {
+		if key.Name == name {
+			return key
+		}
+	}
+	return hosting.SSHKey{}
+}
+
+func (h testKeyHosting) ListKeys() []hosting.SSHKey {
+	return h.keys
+}
+
+func TestGandi_resolveSSHKey(t *testing.T) {
+	ed25519, _ := parseSSHPublicKey(testSSHKeyEd25519)
+	h := testKeyHosting{keys: []hosting.SSHKey{
+		{ID: "1", Name: "laptop", Value: testSSHKeyEd25519, Fingerprint: ed25519.fingerprint()},
+		{ID: "2", Name: "deploy", Value: testSSHKeyRSA2048, Fingerprint: "bf:6e:22:5b:d9:c7:91:b1:7b:7b:ae:85:a0:db:1e:e9"},
+		{ID: "3", Name: "2", Value: testSSHKeyDSA, Fingerprint: "84:27:eb:f3:eb:89:8d:25:de:d9:ce:7e:f3:5e:a1:64"},
+		{ID: "4", Name: "laptop-copy", Value: testSSHKeyEd25519, Fingerprint: ed25519.fingerprint()},
+	}}
+	cases := []struct {
+		Ref   string
+		ID    string
+		Error bool
+	}{
+		{"laptop", "1", false},
+		{"deploy", "2", false},
+		// names take precedence over IDs
+		{"2", "3", false},
+		{"4", "4", false},
+		{"bf:6e:22:5b:d9:c7:91:b1:7b:7b:ae:85:a0:db:1e:e9", "2", false},
+		{"MD5:84:27:EB:F3:EB:89:8D:25:DE:D9:CE:7E:F3:5E:A1:64", "3", false},
+		{"bf6e225bd9c791b17b7bae85a0db1ee9", "2", false},
+		// several keys share the fingerprint
+		{ed25519.fingerprint(), "", true},
+		{ed25519.fingerprintSHA256(), "", true},
+		{"unknown", "", true},
+		{"00:11:22:33", "", true},
+	}
+
+	for i, c := range cases {
+		key, err := resolveSSHKey(h, c.Ref)
+		if (err != nil) != c.Error {
+			t.Fatalf("Error in case %d, expected error %t, got %v instead", i, c.Error, err)
+		}
+		if key.ID != c.ID {
+			t.Fatalf("Error in case %d, expected key %s, got %s instead", i, c.ID, key.ID)
+		}
+	}
+}
+
+func TestGandi_sshKeysReplaceVM(t *testing.T) {
+	ed25519, _ := parseSSHPublicKey(testSSHKeyEd25519)
+	rsa, _ := parseSSHPublicKey(testSSHKeyRSA2048)
+	h := testKeyHosting{keys: []hosting.SSHKey{
+		{ID: "1", Name: "laptop", Value: testSSHKeyEd25519},
+		{ID: "2", Name: "deploy", Value: testSSHKeyRSA2048},
+	}}
+	cases := []struct {
+		old     []string
+		refs    []string
+		replace bool
+	}{
+		{[]string{ed25519.fingerprintSHA256()}, []string{"laptop"}, false},
+		// Referenced by ID or by fingerprint
+		{[]string{ed25519.fingerprintSHA256()}, []string{"1"}, false},
+		{[]string{ed25519.fingerprintSHA256()}, []string{"laptop", ed25519.fingerprint()}, false},
+		// Renamed in the same apply
+		{[]string{ed25519.fingerprintSHA256()}, []string{"laptop-renamed"}, false},
+		{[]string{ed25519.fingerprintSHA256(), rsa.fingerprintSHA256()}, []string{"laptop", "deploy-renamed"}, false},
+		{[]string{ed25519.fingerprintSHA256()}, []string{"deploy"}, true},
+		{[]string{ed25519.fingerprintSHA256()}, []string{"laptop", "new"}, true},
+		{[]string{ed25519.fingerprintSHA256(), rsa.fingerprintSHA256()}, []string{"laptop"}, true},
+		{[]string{ed25519.fingerprintSHA256()}, nil, true},
+		{nil, []string{"laptop"}, true},
+	}
+	for i, c := range cases {
+		if replace := sshKeysReplaceVM(h, c.old, c.refs); replace != c.replace {
+			t.Fatalf("Error in case %d, expected %t, got %t instead", i, c.replace, replace)
+		}
+	}
+}
+
+func TestGandi_checkSSHKeyRefs(t *testing.T) {
+	h := testKeyHosting{keys: []hosting.SSHKey{
+		{ID: "1", Name: "laptop", Value: testSSHKeyEd25519},
+	}}
+	meta := &providerMeta{Hosting: h}
+	meta.planSSHKey("deploy")
+	cases := []struct {
+		refs  []string
+		Error bool
+	}{
+		{[]string{"laptop", "1"}, false},
+		// Created by gandi_ssh in the same apply
+		{[]string{"laptop", "deploy"}, false},
+		{[]string{hcl2shim.UnknownVariableValue}, false},
+		// Misspelled
+		{[]string{"laptpo"}, true},
+		{[]string{"laptop", "deplyo"}, true},
+	}
+	for i, c := range cases {
+		if err := checkSSHKeyRefs(h, meta, c.refs); (err != nil) != c.Error {
+			t.Fatalf("Error in case %d, expected error %t, got %v instead", i, c.Error, err)
+		}
+	}
+	err := checkSSHKeyRefs(h, meta, []string{"laptpo"})
+	if err == nil || !strings.Contains(err.Error(), "SSH key 'laptpo' not found") {
+		t.Fatalf("Expected the misspelled key to be named in the error, got %v instead", err)
+	}
+}
@@ -0,0 +1,101 @@
+package gandi
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// vmOperation is the attachment or detachment of a disk or an ip
+type vmOperation struct {
+	// e.g "attach disk 'data'"
+	description string
+	run         func() error
+}
+
+// runVMOperations runs the operations concurrently, at most `parallel`
+// at a time, and waits for all of them to finish. The error of every
+// operation that failed is returned.
+//
+// Operations that must be ordered, e.g detaching before attaching, are
+// given in separate calls
+func runVMOperations(parallel int, ops []vmOperation) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	sem := make(chan struct{}, parallel)
+	errs := make([]error, len(ops))
+	var wg sync.WaitGroup
+	for i, op := range ops {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, op vmOperation) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			log.Printf("[INFO] Starting to %s...", op.description)
+			if err := op.run(); err != nil {
+				errs[i] = fmt.Errorf("[ERR] Could not %s: %s", op.description, err)
+				return
+			}
+			log.Printf("[INFO] Done: %s", op.description)
+		}(i, op)
+	}
+	wg.Wait()
+
+	var result *multierror.Error
+	for _, err := range errs {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
+}
+
+// maxParallelOperations returns the max_parallel_operations of the provider
+func maxParallelOperations(m interface{}) int {
+	if meta, ok := m.(*providerMeta); ok && meta.maxParallelOperations > 0 {
+		return meta.maxParallelOperations
+	}
+	return 1
+}
+
+func attachDiskOperation(h hosting.Hosting, vm hosting.VM, disk hosting.Disk) vmOperation {
+	return vmOperation{
+		description: fmt.Sprintf("attach disk '%s' to vm '%s'", disk.Name, vm.Hostname),
+		run: func() error {
+			_, _, err := h.AttachDisk(vm, disk)
+			return err
+		},
+	}
+}
+
+func detachDiskOperation(h hosting.Hosting, vm hosting.VM, disk hosting.Disk) vmOperation {
+	return vmOperation{
+		description: fmt.Sprintf("detach disk '%s' from vm '%s'", disk.Name, vm.Hostname),
+		run: func() error {
+			_, _, err := h.DetachDisk(vm, disk)
+			return err
+		},
+	}
+}
+
+func attachIPOperation(h hosting.Hosting, vm hosting.VM, ip hosting.IPAddress) vmOperation {
+	return vmOperation{
+		description: fmt.Sprintf("attach ip '%s' to vm '%s'", ip.IP, vm.Hostname),
+		run: func() error {
+			_, _, err := h.AttachIP(vm, ip)
+			return err
+		},
+	}
+}
+
+func detachIPOperation(h hosting.Hosting, vm hosting.VM, ip hosting.IPAddress) vmOperation {
+	return vmOperation{
+		description: fmt.Sprintf("detach ip '%s' from vm '%s'", ip.IP, vm.Hostname),
+		run: func() error {
+			_, _, err := h.DetachIP(vm, ip)
+			return err
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package gandi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+func TestGandi_runVMOperations(t *testing.T) {
+	cases := []struct {
+		parallel, operations int
+		// Operations that fail, by index
+		failing []int
+	}{
+		{1, 5, nil},
+		{4, 10, nil},
+		{4, 2, nil},
+		// Invalid limits run the operations one at a time
+		{0, 3, nil},
+		{3, 6, []int{1, 4}},
+		{2, 3, []int{0, 1, 2}},
+	}
+	for i, c := range cases {
+		var mu sync.Mutex
+		running, maxrunning, done := 0, 0, 0
+		var ops []vmOperation
+		for j := 0; j < c.operations; j++ {
+			fail := false
+			for _, k := range c.failing {
+				fail = fail || k == j
+			}
+			description := fmt.Sprintf("run operation %d", j)
+			ops = append(ops, vmOperation{
+				description: description,
+				run: func() error {
+					mu.Lock()
+					running++
+					if running > maxrunning {
+						maxrunning = running
+					}
+					mu.Unlock()
+					time.Sleep(5 * time.Millisecond)
+					mu.Lock()
+					running--
+					done++
+					mu.Unlock()
+					if fail {
+						return fmt.Errorf("failed")
+					}
+					return nil
+				},
+			})
+		}
+		err := runVMOperations(c.parallel, ops)
+
+		limit := c.parallel
+		if limit < 1 {
+			limit = 1
+		}
+		if maxrunning > limit {
+			t.Fatalf("Error in case %d, expected at most %d operations at a time, got %d instead", i, limit, maxrunning)
+		}
+		if done != c.operations {
+			t.Fatalf("Error in case %d, expected %d operations done, got %d instead", i, c.operations, done)
+		}
+		if len(c.failing) == 0 {
+			if err != nil {
+				t.Fatalf("Error in case %d, expected no error, got '%s' instead", i, err)
+			}
+			continue
+		}
+		merr, ok := err.(*multierror.Error)
+		if !ok || len(merr.Errors) != len(c.failing) {
+			t.Fatalf("Error in case %d, expected %d errors, got '%v' instead", i, len(c.failing), err)
+		}
+		for j, k := range c.failing {
+			if !strings.Contains(merr.Errors[j].Error(), fmt.Sprintf("operation %d", k)) {
+				t.Fatalf("Error in case %d, expected an error for operation %d, got '%s' instead", i, k, merr.Errors[j])
+			}
+		}
+	}
+}
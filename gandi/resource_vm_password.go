@@ -0,0 +1,117 @@
+package gandi
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Passwords are never stored in the state, only their bcrypt hash, used to
+// detect changes in the configuration
+const (
+	passwordMinLength       = 8
+	passwordMaxLength       = 64
+	generatedPasswordLength = 24
+	passwordSpecialChars    = "!#%*+,-.:=?@_"
+)
+
+// vmPasswordValidate checks a password against Gandi's password policy:
+// between 8 and 64 characters with at least a lowercase letter,
+// an uppercase letter and a digit
+func vmPasswordValidate(value interface{}, name string) (warnings []string, errors []error) {
+	password := value.(string)
+	if len(password) < passwordMinLength || len(password) > passwordMaxLength {
+		errors = append(errors, fmt.Errorf("%q must be between %d and %d characters long", name, passwordMinLength, passwordMaxLength))
+	}
+	var lower, upper, digit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsDigit(c):
+			digit = true
+		}
+	}
+	if !lower || !upper || !digit {
+		errors = append(errors, fmt.Errorf("%q must contain a lowercase letter, an uppercase letter and a digit", name))
+	}
+	return
+}
+
+// generatePassword returns a random password that complies
+// with vmPasswordValidate
+func generatePassword() (string, error) {
+	classes := []string{
+		"abcdefghijklmnopqrstuvwxyz",
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		"0123456789",
+		passwordSpecialChars,
+	}
+	all := strings.Join(classes, "")
+	password := make([]byte, generatedPasswordLength)
+	for i := range password {
+		// the first characters guarantee every class is present
+		charset := all
+		if i < len(classes) {
+			charset = classes[i]
+		}
+		c, err := randomChar(charset)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+	// shuffle, so the classes are not always in the same positions
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+	return charset[n.Int64()], nil
+}
+
+// hashPassword returns the bcrypt hash of a password to be stored in the state
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func isPasswordHash(value string) bool {
+	_, err := bcrypt.Cost([]byte(value))
+	return err == nil
+}
+
+// passwordMatchesHash returns true if `hash` was computed from `password`
+func passwordMatchesHash(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// passwordDiffSuppress compares the password of the configuration with the
+// hash stored in the state, a password removed from the configuration
+// keeps the current one, generated or not
+func passwordDiffSuppress(k, old, new string, d *schema.ResourceData) bool {
+	if !isPasswordHash(old) {
+		return old == new
+	}
+	return new == "" || passwordMatchesHash(new, old)
+}
@@ -0,0 +1,74 @@
+package gandi
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestGandi_vmPasswordValidate(t *testing.T) {
+	cases := []struct {
+		password string
+		valid    bool
+	}{
+		{"Passwordfortest123!", true},
+		{"Abcdefg1", true},
+		{"Abcdef1", false},
+		{"passwordfortest123", false},
+		{"PASSWORDFORTEST123", false},
+		{"Passwordfortest", false},
+	}
+	for i, c := range cases {
+		_, errs := vmPasswordValidate(c.password, "password")
+		if (len(errs) == 0) != c.valid {
+			t.Fatalf("Error in case %d, expected valid %t, got %v instead", i, c.valid, errs)
+		}
+	}
+}
+
+func TestGandi_generatePassword(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		password, err := generatePassword()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, errs := vmPasswordValidate(password, "password"); len(errs) > 0 {
+			t.Fatalf("Generated password %q is not valid: %v", password, errs)
+		}
+	}
+}
+
+func TestGandi_passwordHash(t *testing.T) {
+	hash, err := hashPassword("Passwordfortest123!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isPasswordHash(hash) {
+		t.Fatalf("Error, %q is not a password hash", hash)
+	}
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		t.Fatalf("Error, %q is not a bcrypt hash: %s", hash, err)
+	}
+	if !passwordMatchesHash("Passwordfortest123!", hash) {
+		t.Fatalf("Error, password does not match its hash")
+	}
+	if passwordMatchesHash("Passwordfortest1234!", hash) {
+		t.Fatalf("Error, different password matches the hash")
+	}
+	cases := []struct {
+		old, new string
+		suppress bool
+	}{
+		{hash, "Passwordfortest123!", true},
+		{hash, "", true},
+		{hash, "Otherpassword123!", false},
+		// state written before passwords were hashed
+		{"Passwordfortest123!", "Passwordfortest123!", true},
+		{"Passwordfortest123!", "Otherpassword123!", false},
+	}
+	for i, c := range cases {
+		if suppress := passwordDiffSuppress("userpass.0.password", c.old, c.new, nil); suppress != c.suppress {
+			t.Fatalf("Error in case %d, expected %t, got %t instead", i, c.suppress, suppress)
+		}
+	}
+}
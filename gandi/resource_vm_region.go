@@ -0,0 +1,143 @@
+package gandi
+
+import (
+	"fmt"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/config/hcl2shim"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// vmRegionCheck verifies at plan time that every disk and ip referenced
+// by a vm is in the region of the vm, and that its disks are not
+// attached to another vm
+//
+// Only references whose value is known at plan time and that already
+// exist are checked, disks and ips created in the same apply are left
+// to the API
+func vmRegionCheck(d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChange("region_id") && !d.HasChange("boot_disk") &&
+		!d.HasChange("disks") && !d.HasChange("ips") {
+		return nil
+	}
+	h := meta.(hosting.Hosting)
+	regionid := ""
+	if d.NewValueKnown("region_id") {
+		regionid = d.Get("region_id").(string)
+	}
+	if m, ok := meta.(*providerMeta); ok && regionid == "" {
+		regionid = m.defaultRegion.ID
+	}
+
+	var disknames []string
+	for _, key := range []string{"boot_disk", "disks"} {
+		if !d.NewValueKnown(key) {
+			continue
+		}
+		disknames = append(disknames, knownValues(d.Get(key), "name")...)
+	}
+	var ipids []string
+	if d.NewValueKnown("ips") {
+		ipids = knownValues(d.Get("ips"), "id")
+	}
+
+	disks, ips, err := lookupDisksAndIPs(h, regionid, disknames, ipids)
+	if err != nil {
+		return err
+	}
+	return regionMismatches(d.Id(), regionid, disknames, disks, ipids, ips)
+}
+
+// lookupDisksAndIPs returns the disks and ips referenced by a vm, with a
+// single list per region when the hosting supports it, c.f batchLookup
+func lookupDisksAndIPs(h hosting.Hosting, regionid string, disknames, ipids []string) ([]hosting.Disk, []hosting.IPAddress, error) {
+	if b, ok := batchLookupOf(h); ok {
+		disks, err := b.disksByName(regionid, disknames)
+		if err != nil {
+			return nil, nil, err
+		}
+		ips, err := b.ipsByID(regionid, ipids)
+		return disks, ips, err
+	}
+	var disks []hosting.Disk
+	for _, name := range disknames {
+		if disk := h.DiskFromName(name); disk.ID != "" {
+			disks = append(disks, disk)
+		}
+	}
+	var ips []hosting.IPAddress
+	for _, id := range ipids {
+		found, err := h.ListIPs(hosting.IPFilter{ID: id})
+		if err != nil {
+			return nil, nil, err
+		}
+		ips = append(ips, found...)
+	}
+	return disks, ips, nil
+}
+
+// regionMismatches returns an error for every disk or ip referenced that
+// is not in `regionid` or is a disk attached to another vm than `vmid`,
+// references not found are skipped since they may be created in the
+// same apply
+func regionMismatches(vmid, regionid string, disknames []string, disks []hosting.Disk, ipids []string, ips []hosting.IPAddress) error {
+	disksbyname := map[string]hosting.Disk{}
+	for _, disk := range disks {
+		disksbyname[disk.Name] = disk
+	}
+	ipsbyid := map[string]hosting.IPAddress{}
+	for _, ip := range ips {
+		ipsbyid[ip.ID] = ip
+	}
+
+	var errs *multierror.Error
+	for _, name := range disknames {
+		disk, ok := disksbyname[name]
+		if !ok {
+			continue
+		}
+		if regionid != "" && disk.RegionID != regionid {
+			errs = multierror.Append(errs, fmt.Errorf("Disk '%s' is in region %s, the vm is in region %s", name, disk.RegionID, regionid))
+		}
+		for _, attachedvm := range disk.VM {
+			if attachedvm != vmid {
+				errs = multierror.Append(errs, fmt.Errorf("Disk '%s' is already attached to vm %s", name, attachedvm))
+			}
+		}
+	}
+	for _, id := range ipids {
+		ip, ok := ipsbyid[id]
+		if !ok {
+			continue
+		}
+		if regionid != "" && ip.RegionID != regionid {
+			errs = multierror.Append(errs, fmt.Errorf("IP '%s' is in region %s, the vm is in region %s", ip.IP, ip.RegionID, regionid))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// knownValues returns the values of `key` in a list or set of blocks,
+// ignoring the values not known yet
+func knownValues(raw interface{}, key string) (values []string) {
+	var blocks []interface{}
+	switch v := raw.(type) {
+	case []interface{}:
+		blocks = v
+	case *schema.Set:
+		blocks = v.List()
+	}
+	for _, block := range blocks {
+		blockmap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, _ := blockmap[key].(string)
+		if value == "" || value == hcl2shim.UnknownVariableValue {
+			continue
+		}
+		values = append(values, value)
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package gandi
+
+import (
+	"testing"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+func TestGandi_regionMismatches(t *testing.T) {
+	disks := []hosting.Disk{
+		{ID: "1", Name: "boot", RegionID: "1", VM: []string{"10"}},
+		{ID: "2", Name: "data", RegionID: "1"},
+		{ID: "3", Name: "other", RegionID: "2"},
+		{ID: "4", Name: "used", RegionID: "1", VM: []string{"20"}},
+	}
+	ips := []hosting.IPAddress{
+		{ID: "1", IP: "203.0.113.10", RegionID: "1"},
+		{ID: "2", IP: "203.0.113.11", RegionID: "2"},
+	}
+	cases := []struct {
+		vmid, regionid string
+		disknames      []string
+		ipids          []string
+		errors         int
+	}{
+		{"10", "1", []string{"boot", "data"}, []string{"1"}, 0},
+		// Boot disk attached to the vm being created
+		{"", "1", []string{"boot"}, nil, 1},
+		{"10", "1", []string{"other"}, []string{"2"}, 2},
+		{"10", "1", []string{"used"}, nil, 1},
+		// Disk and ip created in the same apply
+		{"10", "1", []string{"missing"}, []string{"3"}, 0},
+		{"10", "1", []string{"missing", "other"}, nil, 1},
+		// Unknown region
+		{"10", "", []string{"boot", "other"}, []string{"1", "2"}, 0},
+	}
+	for i, c := range cases {
+		err := regionMismatches(c.vmid, c.regionid, c.disknames, disks, c.ipids, ips)
+		errors := 0
+		if err != nil {
+			errors = len(err.(*multierror.Error).Errors)
+		}
+		if errors != c.errors {
+			t.Fatalf("Error in case %d, expected %d errors, got '%v' instead", i, c.errors, err)
+		}
+	}
+}
+
+func TestGandi_lookupDisksAndIPs(t *testing.T) {
+	h := &testCountingHosting{
+		disks: []hosting.Disk{
+			{ID: "1", Name: "boot", RegionID: "1"},
+			{ID: "2", Name: "data", RegionID: "1"},
+		},
+		ips: []hosting.IPAddress{
+			{ID: "1", RegionID: "1"},
+			{ID: "2", RegionID: "1"},
+		},
+	}
+	meta := &providerMeta{Hosting: newCachingHosting(h, false)}
+	disks, ips, err := lookupDisksAndIPs(meta, "1", []string{"boot", "data"}, []string{"1", "2"})
+	if err != nil || len(disks) != 2 || len(ips) != 2 {
+		t.Fatalf("Expected 2 disks and 2 ips, got %v, %v (%v) instead", disks, ips, err)
+	}
+	// One list of the disks and one of the ips of the region
+	if h.calls != 2 {
+		t.Fatalf("Expected 2 list calls, got %d instead", h.calls)
+	}
+}
+
+func TestGandi_regionMismatchesNewDisk(t *testing.T) {
+	// The boot disk is created in the same apply, as in
+	// boot_disk { name = "${gandi_disk.sd1.name}" }
+	h := &testCountingHosting{
+		ips: []hosting.IPAddress{{ID: "1", RegionID: "1"}},
+	}
+	meta := &providerMeta{Hosting: newCachingHosting(h, false)}
+	disknames := []string{"d9_sysdisk"}
+	disks, ips, err := lookupDisksAndIPs(meta, "1", disknames, []string{"1"})
+	if err != nil {
+		t.Fatalf("Error looking up the disks and ips: %v", err)
+	}
+	if err := regionMismatches("", "1", disknames, disks, []string{"1"}, ips); err != nil {
+		t.Fatalf("Expected a disk not created yet to be skipped, got '%v' instead", err)
+	}
+}
@@ -0,0 +1,135 @@
+package gandi
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// vmLimits are the cores and memory an account can still give to its vms,
+// c.f vmAccountLimits. Gandi grants these per account and not per region,
+// the same limits apply in every region. A zero maximum is not a limit
+type vmLimits struct {
+	MaxCores  int
+	MaxMemory int // in MB
+}
+
+// vmAccountLimits returns the resources available to the account, from
+// hosting.account.info, they are read once per provider run
+func vmAccountLimits(m interface{}) (vmLimits, error) {
+	meta, ok := m.(*providerMeta)
+	if !ok || meta.client == nil {
+		return vmLimits{}, nil
+	}
+	meta.limitsOnce.Do(func() {
+		response := struct {
+			Resources struct {
+				Available struct {
+					Cores  int `xmlrpc:"cores"`
+					Memory int `xmlrpc:"memory"`
+				} `xmlrpc:"available"`
+			} `xmlrpc:"resources"`
+		}{}
+		meta.limitsErr = meta.client.Send("hosting.account.info", []interface{}{}, &response)
+		meta.limits = vmLimits{
+			MaxCores:  response.Resources.Available.Cores,
+			MaxMemory: response.Resources.Available.Memory,
+		}
+	})
+	return meta.limits, meta.limitsErr
+}
+
+// check returns an error for every value out of bounds, the old values
+// are already allocated to the vm and do not count against the limits.
+// A zero value means the value was not provided and is ignored
+func (l vmLimits) check(oldcores, cores, oldmemory, memory int) error {
+	var errs *multierror.Error
+	if cores < 0 || (cores == 0 && oldcores != 0) {
+		errs = multierror.Append(errs, fmt.Errorf("cores must be at least 1, got: %d", cores))
+	}
+	if memory < 0 || (memory == 0 && oldmemory != 0) {
+		errs = multierror.Append(errs, fmt.Errorf("memory must be positive, got: %d", memory))
+	}
+	if l.MaxCores > 0 && cores-oldcores > l.MaxCores {
+		errs = multierror.Append(errs, fmt.Errorf("cores can be increased by at most %d, "+
+			"the cores available to the account, got: %d", l.MaxCores, cores-oldcores))
+	}
+	if l.MaxMemory > 0 && memory-oldmemory > l.MaxMemory {
+		errs = multierror.Append(errs, fmt.Errorf("memory can be increased by at most %d MB, "+
+			"the memory available to the account, got: %d", l.MaxMemory, memory-oldmemory))
+	}
+	return errs.ErrorOrNil()
+}
+
+// vmLimitsCheck validates cores and memory against the resources available
+// to the account at plan time
+func vmLimitsCheck(d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChange("cores") && !d.HasChange("memory") {
+		return nil
+	}
+	var oldcores, cores, oldmemory, memory int
+	if d.NewValueKnown("cores") {
+		old, new := d.GetChange("cores")
+		oldcores, cores = old.(int), new.(int)
+	}
+	if d.NewValueKnown("memory") {
+		old, new := d.GetChange("memory")
+		oldmemory, memory = old.(int), new.(int)
+	}
+	limits, err := vmAccountLimits(meta)
+	if err != nil {
+		log.Printf("[WARN] Could not get the resources available to the account: %s", err)
+	}
+	return limits.check(oldcores, cores, oldmemory, memory)
+}
+
+// restartOnError starts a vm stopped for an update that failed, leaving
+// it running as it was found
+func restartOnError(h hosting.Hosting, vm hosting.VM, stopped bool, err error, timeout time.Duration) error {
+	if !stopped {
+		return err
+	}
+	if serr := startVM(h, vm, timeout); serr != nil {
+		return multierror.Append(err, serr)
+	}
+	return err
+}
+
+// vmResizeNeedsHalt returns true if the vm has to be stopped to change
+// its memory and cores from the old values to the new ones
+//
+// Memory can be increased on a running vm up to the maximum set on boot,
+// `maxmemory`, decreasing memory or cores needs a stop/start cycle.
+// An unknown maximum (0) is not considered a limit
+func vmResizeNeedsHalt(oldmemory, newmemory, maxmemory, oldcores, newcores int) bool {
+	if newmemory < oldmemory || newcores < oldcores {
+		return true
+	}
+	return maxmemory > 0 && newmemory > maxmemory
+}
+
+// vmMaxMemory returns the memory a running vm can be extended to without
+// being stopped, this value is not exposed by the hosting library so the
+// API is called directly when possible
+func vmMaxMemory(m interface{}, id string) (int, error) {
+	caller, ok := v4Caller(m)
+	if !ok {
+		return 0, nil
+	}
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, err
+	}
+	response := struct {
+		MaxMemory int `xmlrpc:"vm_max_memory"`
+	}{}
+	if err := caller.Send("hosting.vm.info", []interface{}{vmid}, &response); err != nil {
+		return 0, err
+	}
+	return response.MaxMemory, nil
+}
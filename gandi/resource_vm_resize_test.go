@@ -0,0 +1,100 @@
+package gandi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PabloPie/go-gandi/hosting"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+func TestGandi_vmResizeNeedsHalt(t *testing.T) {
+	cases := []struct {
+		oldmemory, newmemory, maxmemory int
+		oldcores, newcores              int
+		needshalt                       bool
+	}{
+		// Memory increase below the max memory
+		{512, 1024, 2048, 1, 1, false},
+		// Memory increase up to the max memory
+		{512, 2048, 2048, 1, 1, false},
+		// Memory increase above the max memory
+		{512, 4096, 2048, 1, 1, true},
+		// Unknown max memory
+		{512, 4096, 0, 1, 1, false},
+		// Memory decrease
+		{1024, 512, 2048, 1, 1, true},
+		// Cores increase
+		{512, 512, 2048, 1, 2, false},
+		// Cores decrease
+		{512, 512, 2048, 2, 1, true},
+	}
+	for i, c := range cases {
+		needshalt := vmResizeNeedsHalt(c.oldmemory, c.newmemory, c.maxmemory, c.oldcores, c.newcores)
+		if needshalt != c.needshalt {
+			t.Fatalf("Error in case %d, expected %t, got %t instead", i, c.needshalt, needshalt)
+		}
+	}
+}
+
+func TestGandi_vmLimitsCheck(t *testing.T) {
+	limits := vmLimits{MaxCores: 4, MaxMemory: 2048}
+	cases := []struct {
+		limits            vmLimits
+		oldcores, cores   int
+		oldmemory, memory int
+		errors            int
+	}{
+		{limits, 0, 1, 0, 512, 0},
+		// Unknown values are ignored
+		{limits, 0, 0, 0, 0, 0},
+		{limits, 0, 8, 0, 512, 1},
+		{limits, 0, 8, 0, 4096, 2},
+		// Resources of the vm are already allocated
+		{limits, 4, 8, 2048, 4096, 0},
+		{limits, 4, 16, 2048, 8192, 2},
+		// Decreases are always possible
+		{limits, 16, 1, 8192, 512, 0},
+		{limits, 2, 0, 512, -64, 2},
+		// Unknown limits
+		{vmLimits{}, 0, 64, 0, 1 << 20, 0},
+	}
+	for i, c := range cases {
+		err := c.limits.check(c.oldcores, c.cores, c.oldmemory, c.memory)
+		errors := 0
+		if err != nil {
+			errors = len(err.(*multierror.Error).Errors)
+		}
+		if errors != c.errors {
+			t.Fatalf("Error in case %d, expected %d errors, got '%v' instead", i, c.errors, err)
+		}
+	}
+}
+
+func TestGandi_restartOnError(t *testing.T) {
+	cases := []struct {
+		stopped bool
+		fail    map[string]int
+		state   string
+		errors  int
+	}{
+		{true, nil, "running", 1},
+		{false, nil, "halted", 1},
+		{true, map[string]int{"start": 1}, "halted", 2},
+	}
+	for i, c := range cases {
+		h := &testVMHosting{vm: hosting.VM{ID: "1", State: "halted"}, fail: c.fail}
+		err := restartOnError(h, h.vm, c.stopped, fmt.Errorf("resize failed"), time.Minute)
+		errors := 1
+		if merr, ok := err.(*multierror.Error); ok {
+			errors = len(merr.Errors)
+		}
+		if errors != c.errors {
+			t.Fatalf("Error in case %d, expected %d errors, got '%v' instead", i, c.errors, err)
+		}
+		if h.vm.State != c.state {
+			t.Fatalf("Error in case %d, expected vm %s, got %s instead", i, c.state, h.vm.State)
+		}
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/terraform"
 
 	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/config/hcl2shim"
 	"github.com/hashicorp/terraform/helper/acctest"
 	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
 )
 
 func TestGandi_testContains(t *testing.T) {
@@ -155,6 +159,78 @@ func TestGandi_ipDiff(t *testing.T) {
 	}
 }
 
+func TestGandi_knownValues(t *testing.T) {
+	cases := []struct {
+		raw    interface{}
+		values []string
+	}{
+		{
+			raw: []interface{}{
+				map[string]interface{}{"name": "disk1"},
+			},
+			values: []string{"disk1"},
+		},
+		{
+			raw: schema.NewSet(schema.HashResource(&schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {Type: schema.TypeString, Optional: true},
+				},
+			}), []interface{}{
+				map[string]interface{}{"name": "disk1"},
+				map[string]interface{}{"name": ""},
+				map[string]interface{}{"name": hcl2shim.UnknownVariableValue},
+			}),
+			values: []string{"disk1"},
+		},
+		{
+			raw: []interface{}{},
+		},
+	}
+	for i, c := range cases {
+		values := knownValues(c.raw, "name")
+		if !reflect.DeepEqual(values, c.values) {
+			t.Fatalf("Error in case %d, expected %#v, got %#v instead", i, c.values, values)
+		}
+	}
+}
+
+func TestGandi_publicIPs(t *testing.T) {
+	cases := []struct {
+		ips  []hosting.IPAddress
+		ipv4 string
+		ipv6 string
+	}{
+		{
+			ips: []hosting.IPAddress{
+				{IP: "192.168.1.2"},
+				{IP: "2001:db8::1"},
+				{IP: "203.0.113.10"},
+				{IP: "203.0.113.11"},
+			},
+			ipv4: "203.0.113.10",
+			ipv6: "2001:db8::1",
+		},
+		{
+			ips: []hosting.IPAddress{
+				{IP: "10.0.0.2"},
+				{IP: "fd00::1"},
+			},
+		},
+		{
+			ips: []hosting.IPAddress{
+				{IP: "2001:db8::1"},
+			},
+			ipv6: "2001:db8::1",
+		},
+	}
+	for i, c := range cases {
+		ipv4, ipv6 := publicIPs(c.ips)
+		if ipv4 != c.ipv4 || ipv6 != c.ipv6 {
+			t.Fatalf("Error in case %d, expected (%q, %q), got (%q, %q) instead", i, c.ipv4, c.ipv6, ipv4, ipv6)
+		}
+	}
+}
+
 func TestAccGandiVM_basic(t *testing.T) {
 	vmname := fmt.Sprintf("gandivm-%d", acctest.RandIntRange(1, 1000))
 	vmconfig := fmt.Sprintf(testAccGandiVMbasic, vmname)
@@ -169,12 +245,142 @@ func TestAccGandiVM_basic(t *testing.T) {
 					resource.TestCheckResourceAttr("gandi_vm.accTestVM", "name", vmname),
 					resource.TestCheckResourceAttr("gandi_vm.accTestVM", "cores", "1"),
 					resource.TestCheckResourceAttr("gandi_vm.accTestVM", "memory", "512"),
+					resource.TestCheckResourceAttrSet("gandi_vm.accTestVM", "public_ipv6"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccGandiVM_import(t *testing.T) {
+	vmname := fmt.Sprintf("gandivm-%d", acctest.RandIntRange(1, 1000))
+	vmconfig := fmt.Sprintf(testAccGandiVMbasic, vmname)
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: vmconfig,
+			},
+			{
+				ResourceName:     "gandi_vm.accTestVM",
+				ImportState:      true,
+				ImportStateCheck: testCheckGandiVMImportPlan,
+			},
+		},
+	})
+}
+
+// testCheckGandiVMImportPlan checks the configuration of the vm plans
+// without changes against its imported state
+func testCheckGandiVMImportPlan(states []*terraform.InstanceState) error {
+	if len(states) != 1 {
+		return fmt.Errorf("[ERR] Expected 1 imported vm, got %d", len(states))
+	}
+	is := states[0]
+	raw, err := config.NewRawConfig(map[string]interface{}{
+		"region_id": is.Attributes["region_id"],
+		"name":      is.Attributes["name"],
+		"ips": []interface{}{
+			map[string]interface{}{"id": is.Attributes["ips.0.id"]},
+		},
+		"boot_disk": []interface{}{
+			map[string]interface{}{"name": is.Attributes["boot_disk.0.name"]},
+		},
+		"userpass": []interface{}{
+			map[string]interface{}{"login": "testlogin", "password": "Passwordfortest123!"},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	diff, err := resourceVM().Diff(is, terraform.NewResourceConfig(raw), testAccProvider.Meta())
+	if err != nil {
+		return err
+	}
+	if !diff.Empty() {
+		return fmt.Errorf("[ERR] Expected no changes after import, got %#v instead", diff.Attributes)
+	}
+	return nil
+}
+
+func TestAccGandiVM_powerState(t *testing.T) {
+	vmname := fmt.Sprintf("gandivm-%d", acctest.RandIntRange(1, 1000))
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccGandiVMPowerState, vmname, "halted", "v1"),
+				Check: resource.ComposeTestCheckFunc(
+					testCheckGandiVMExists("gandi_vm.accTestVM"),
+					resource.TestCheckResourceAttr("gandi_vm.accTestVM", "state", "halted"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(testAccGandiVMPowerState, vmname, "running", "v1"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("gandi_vm.accTestVM", "state", "running"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(testAccGandiVMPowerState, vmname, "running", "v2"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("gandi_vm.accTestVM", "state", "running"),
+					resource.TestCheckResourceAttr("gandi_vm.accTestVM", "reboot_triggers.config", "v2"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccGandiVM_deleteOnDestroy(t *testing.T) {
+	vmname := fmt.Sprintf("gandivm-%d", acctest.RandIntRange(1, 1000))
+	vmconfig := fmt.Sprintf(testAccGandiVMDeleteOnDestroy, vmname)
+	var diskid, ipid string
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckGandiVMCascadeDestroy(&diskid, &ipid),
+		Steps: []resource.TestStep{
+			{
+				Config: vmconfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckGandiVMExists("gandi_vm.accTestVM"),
+					func(s *terraform.State) error {
+						diskid = s.RootModule().Resources["gandi_disk.systemdisk1"].Primary.ID
+						ipid = s.RootModule().Resources["gandi_ip.ip1"].Primary.ID
+						return nil
+					},
 				),
 			},
 		},
 	})
 }
 
+// The disk and the ip are also managed resources, their own destroy
+// is a no-op once the vm destroy already deleted them
+func testCheckGandiVMCascadeDestroy(diskid, ipid *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		h := testAccProvider.Meta().(hosting.Hosting)
+		disks, err := h.ListDisks(hosting.DiskFilter{ID: *diskid})
+		if err != nil {
+			return err
+		}
+		if len(disks) > 0 {
+			return fmt.Errorf("Error: Disk %q still exists", *diskid)
+		}
+		ips, err := h.ListIPs(hosting.IPFilter{ID: *ipid})
+		if err != nil {
+			return err
+		}
+		if len(ips) > 0 {
+			return fmt.Errorf("Error: IP %q still exists", *ipid)
+		}
+		return nil
+	}
+}
+
 func testCheckGandiVMExists(vm string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[vm]
@@ -229,3 +435,264 @@ resource "gandi_vm" "accTestVM" {
   }
 }
 `
+
+var testAccGandiVMDeleteOnDestroy = `
+data "gandi_region" "datacenter" {
+    region_code = "FR-SD6"
+}
+
+data "gandi_image" "debian9" {
+  name = "Debian 9"
+  region_id = "${data.gandi_region.datacenter.id}"
+}
+
+resource "gandi_ip" "ip1" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  version = 6
+}
+
+resource "gandi_disk" "systemdisk1" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  src_disk_id = "${data.gandi_image.debian9.disk_id}"
+}
+
+resource "gandi_vm" "accTestVM" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  name = "%s"
+  ips {
+    id = "${gandi_ip.ip1.id}"
+  }
+  boot_disk {
+    name = "${gandi_disk.systemdisk1.name}"
+  }
+  userpass {
+    login = "testlogin"
+    password = "Passwordfortest123!"
+  }
+  delete_boot_disk_on_destroy = true
+  release_ips_on_destroy = true
+}
+`
+
+var testAccGandiVMPowerState = `
+data "gandi_region" "datacenter" {
+    region_code = "FR-SD6"
+}
+
+data "gandi_image" "debian9" {
+  name = "Debian 9"
+  region_id = "${data.gandi_region.datacenter.id}"
+}
+
+resource "gandi_ip" "ip1" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  version = 6
+}
+
+resource "gandi_disk" "systemdisk1" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  src_disk_id = "${data.gandi_image.debian9.disk_id}"
+}
+
+resource "gandi_vm" "accTestVM" {
+  region_id = "${data.gandi_region.datacenter.id}"
+  name = "%s"
+  ips {
+    id = "${gandi_ip.ip1.id}"
+  }
+  boot_disk {
+    name = "${gandi_disk.systemdisk1.name}"
+  }
+  userpass {
+    login = "testlogin"
+    password = "Passwordfortest123!"
+  }
+  desired_state = "%s"
+  reboot_triggers = {
+    config = "%s"
+  }
+}
+`
+
+// testVMHosting keeps the state and the disks of a single vm, `fail` holds
+// how many times an operation, e.g "detach old", fails before succeeding
+type testVMHosting struct {
+	hosting.Hosting
+	vm   hosting.VM
+	fail map[string]int
+}
+
+func (h *testVMHosting) failed(op string) error {
+	if h.fail[op] > 0 {
+		h.fail[op]--
+		return fmt.Errorf("%s failed", op)
+	}
+	return nil
+}
+
+func (h *testVMHosting) ListVMs(hosting.VMFilter) ([]hosting.VM, error) {
+	vm := h.vm
+	vm.Disks = append([]hosting.Disk{}, h.vm.Disks...)
+	return []hosting.VM{vm}, nil
+}
+
+func (h *testVMHosting) StopVM(hosting.VM) error {
+	if err := h.failed("stop"); err != nil {
+		return err
+	}
+	h.vm.State = "halted"
+	return nil
+}
+
+func (h *testVMHosting) StartVM(hosting.VM) error {
+	if err := h.failed("start"); err != nil {
+		return err
+	}
+	h.vm.State = "running"
+	return nil
+}
+
+func (h *testVMHosting) AttachDiskAtPosition(vm hosting.VM, disk hosting.Disk, position int) (hosting.VM, hosting.Disk, error) {
+	if err := h.failed("attach " + disk.Name); err != nil {
+		return vm, disk, err
+	}
+	if diskPosition(h.vm, disk) >= 0 {
+		return vm, disk, fmt.Errorf("disk %s is already attached", disk.Name)
+	}
+	disks := append([]hosting.Disk{}, h.vm.Disks[:position]...)
+	disks = append(disks, disk)
+	h.vm.Disks = append(disks, h.vm.Disks[position:]...)
+	return h.vm, disk, nil
+}
+
+func (h *testVMHosting) DetachDisk(vm hosting.VM, disk hosting.Disk) (hosting.VM, hosting.Disk, error) {
+	if err := h.failed("detach " + disk.Name); err != nil {
+		return vm, disk, err
+	}
+	position := diskPosition(h.vm, disk)
+	if position < 0 {
+		return vm, disk, fmt.Errorf("disk %s is not attached", disk.Name)
+	}
+	h.vm.Disks = append(h.vm.Disks[:position:position], h.vm.Disks[position+1:]...)
+	return h.vm, disk, nil
+}
+
+func (h *testVMHosting) AttachDisk(vm hosting.VM, disk hosting.Disk) (hosting.VM, hosting.Disk, error) {
+	return h.AttachDiskAtPosition(vm, disk, len(h.vm.Disks))
+}
+
+func (h *testVMHosting) AttachIP(vm hosting.VM, ip hosting.IPAddress) (hosting.VM, hosting.IPAddress, error) {
+	if err := h.failed("attach " + ip.IP); err != nil {
+		return vm, ip, err
+	}
+	h.vm.Ips = append(h.vm.Ips, ip)
+	return h.vm, ip, nil
+}
+
+func (h *testVMHosting) DetachIP(vm hosting.VM, ip hosting.IPAddress) (hosting.VM, hosting.IPAddress, error) {
+	if err := h.failed("detach " + ip.IP); err != nil {
+		return vm, ip, err
+	}
+	for i, attached := range h.vm.Ips {
+		if attached.ID == ip.ID {
+			h.vm.Ips = append(h.vm.Ips[:i:i], h.vm.Ips[i+1:]...)
+			return h.vm, ip, nil
+		}
+	}
+	return vm, ip, fmt.Errorf("ip %s is not attached", ip.IP)
+}
+
+func TestGandi_keepAttachedDisksAndIPs(t *testing.T) {
+	boot := hosting.Disk{ID: "1", Name: "boot"}
+	old := hosting.Disk{ID: "2", Name: "old", Size: 10}
+	data1 := hosting.Disk{ID: "3", Name: "data1", Size: 10}
+	data2 := hosting.Disk{ID: "4", Name: "data2", Size: 10}
+	oldip := hosting.IPAddress{ID: "1", IP: "203.0.113.1"}
+	ip1 := hosting.IPAddress{ID: "2", IP: "203.0.113.2"}
+	ip2 := hosting.IPAddress{ID: "3", IP: "203.0.113.3"}
+	ipv6 := hosting.IPAddress{ID: "4", IP: "2001:db8::1"}
+	h := &testVMHosting{
+		vm: hosting.VM{
+			ID:    "1",
+			Disks: []hosting.Disk{boot, old},
+			Ips:   []hosting.IPAddress{oldip, ipv6},
+		},
+		fail: map[string]int{"attach data2": 1, "attach " + ip2.IP: 1},
+	}
+	d := schema.TestResourceDataRaw(t, resourceVM().Schema, map[string]interface{}{
+		"disks": []interface{}{
+			map[string]interface{}{"name": "data1"},
+			map[string]interface{}{"name": "data2"},
+		},
+	})
+
+	// The old disk is detached, only data1 is attached
+	if err := runVMOperations(1, []vmOperation{detachDiskOperation(h, h.vm, old)}); err != nil {
+		t.Fatal(err)
+	}
+	err := runVMOperations(2, []vmOperation{attachDiskOperation(h, h.vm, data1), attachDiskOperation(h, h.vm, data2)})
+	if err == nil {
+		t.Fatalf("Expected the attachment of data2 to fail")
+	}
+	if kerr := keepAttachedDisks(d, h, h.vm, err); kerr != err {
+		t.Fatalf("Expected the error of the attachment, got '%v' instead", kerr)
+	}
+	disks := d.Get("disks").(*schema.Set).List()
+	if len(disks) != 1 || disks[0].(map[string]interface{})["id"] != data1.ID {
+		t.Fatalf("Expected only disk data1 in the state, got %v instead", disks)
+	}
+
+	err = runVMOperations(1, []vmOperation{
+		detachIPOperation(h, h.vm, oldip), attachIPOperation(h, h.vm, ip1), attachIPOperation(h, h.vm, ip2),
+	})
+	if err == nil {
+		t.Fatalf("Expected the attachment of ip %s to fail", ip2.IP)
+	}
+	keepAttachedIPs(d, h, h.vm, []hosting.IPAddress{oldip, ip1, ip2}, err)
+	ips := d.Get("ips").(*schema.Set).List()
+	if len(ips) != 1 || ips[0].(map[string]interface{})["id"] != ip1.ID {
+		t.Fatalf("Expected only ip %s in the state, got %v instead", ip1.IP, ips)
+	}
+}
+
+func TestGandi_swapBootDisk(t *testing.T) {
+	old := hosting.Disk{ID: "1", Name: "old"}
+	new := hosting.Disk{ID: "2", Name: "new"}
+	data := hosting.Disk{ID: "3", Name: "data"}
+	cases := []struct {
+		desired string
+		fail    map[string]int
+		disks   []hosting.Disk
+		state   string
+		err     bool
+	}{
+		{"running", nil, []hosting.Disk{new, data}, "running", false},
+		{"running", map[string]int{"attach new": 1}, []hosting.Disk{old, data}, "running", true},
+		// New disk attached at position 0, the old one at position 1
+		{"running", map[string]int{"detach old": 1}, []hosting.Disk{old, data}, "running", true},
+		{"running", map[string]int{"start": 1}, []hosting.Disk{old, data}, "running", true},
+		{"running", map[string]int{"stop": 1}, []hosting.Disk{old, data}, "running", true},
+		// Rollback failures leave the vm halted
+		{"running", map[string]int{"detach old": 1, "detach new": 1}, []hosting.Disk{new, old, data}, "halted", true},
+		{"running", map[string]int{"start": 2}, []hosting.Disk{old, data}, "halted", true},
+		// A vm that must be halted is not started again
+		{"halted", map[string]int{"start": 1}, []hosting.Disk{new, data}, "halted", false},
+		{"halted", map[string]int{"attach new": 1, "start": 1}, []hosting.Disk{old, data}, "halted", true},
+	}
+	for i, c := range cases {
+		h := &testVMHosting{
+			vm:   hosting.VM{ID: "1", State: "running", Disks: []hosting.Disk{old, data}},
+			fail: c.fail,
+		}
+		err := swapBootDisk(h, h.vm, old, new, c.desired, time.Minute)
+		if (err != nil) != c.err {
+			t.Fatalf("Error in case %d, expected error %t, got '%v' instead", i, c.err, err)
+		}
+		if !reflect.DeepEqual(h.vm.Disks, c.disks) {
+			t.Fatalf("Error in case %d, expected disks %v, got %v instead", i, c.disks, h.vm.Disks)
+		}
+		if h.vm.State != c.state {
+			t.Fatalf("Error in case %d, expected vm %s, got %s instead", i, c.state, h.vm.State)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package gandi
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// sshPublicKey is a public key in the authorized_keys format:
+// "<type> <base64 blob> [comment]"
+type sshPublicKey struct {
+	Type    string
+	Blob    []byte
+	Comment string
+
+	// Size of the key for RSA and DSA keys, 0 otherwise
+	Bits int
+}
+
+// Fields of the blob of each key type, after the type itself, c.f RFC 4253,
+// RFC 5656, RFC 8709 and OpenSSH's PROTOCOL.u2f and PROTOCOL.certkeys
+const (
+	fieldMPInt  = "mpint"
+	fieldString = "string"
+)
+
+type sshKeyFormat struct {
+	// Fields of the public key
+	fields []string
+	// Index of the field giving the size of the key, -1 if there is none
+	sizefield int
+	// Size of the field holding the key for fixed size keys
+	keysize map[int]int
+}
+
+var sshKeyFormats = map[string]sshKeyFormat{
+	"ssh-rsa": {
+		// e, n
+		fields:    []string{fieldMPInt, fieldMPInt},
+		sizefield: 1,
+	},
+	"ssh-dss": {
+		// p, q, g, y
+		fields:    []string{fieldMPInt, fieldMPInt, fieldMPInt, fieldMPInt},
+		sizefield: 0,
+	},
+	"ssh-ed25519": {
+		fields:    []string{fieldString},
+		sizefield: -1,
+		keysize:   map[int]int{0: 32},
+	},
+	"ecdsa-sha2-nistp256": {
+		// curve, point
+		fields:    []string{fieldString, fieldString},
+		sizefield: -1,
+		keysize:   map[int]int{1: 65},
+	},
+	"ecdsa-sha2-nistp384": {
+		fields:    []string{fieldString, fieldString},
+		sizefield: -1,
+		keysize:   map[int]int{1: 97},
+	},
+	"ecdsa-sha2-nistp521": {
+		fields:    []string{fieldString, fieldString},
+		sizefield: -1,
+		keysize:   map[int]int{1: 133},
+	},
+	"sk-ssh-ed25519@openssh.com": {
+		// key, application
+		fields:    []string{fieldString, fieldString},
+		sizefield: -1,
+		keysize:   map[int]int{0: 32},
+	},
+	"sk-ecdsa-sha2-nistp256@openssh.com": {
+		// curve, point, application
+		fields:    []string{fieldString, fieldString, fieldString},
+		sizefield: -1,
+		keysize:   map[int]int{1: 65},
+	},
+}
+
+const sshCertSuffix = "-cert-v01@openssh.com"
+
+// parseSSHPublicKey parses and validates a public key, certificates are
+// accepted and validated as the key they certify
+func parseSSHPublicKey(value string) (sshPublicKey, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return sshPublicKey{}, errors.New("expected '<type> <base64 key> [comment]'")
+	}
+	key := sshPublicKey{
+		Type:    fields[0],
+		Comment: strings.Join(fields[2:], " "),
+	}
+	basetype, cert := key.baseType()
+	format, ok := sshKeyFormats[basetype]
+	if !ok {
+		return sshPublicKey{}, fmt.Errorf("unsupported key type '%s'", key.Type)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return sshPublicKey{}, fmt.Errorf("invalid base64 encoding: %s", err)
+	}
+	key.Blob = blob
+
+	r := bytes.NewReader(blob)
+	blobtype, err := readSSHString(r)
+	if err != nil {
+		return sshPublicKey{}, fmt.Errorf("invalid key: %s", err)
+	}
+	if string(blobtype) != key.Type {
+		return sshPublicKey{}, fmt.Errorf("key type '%s' does not match the encoded key type '%s'", key.Type, blobtype)
+	}
+	if cert {
+		if _, err := readSSHString(r); err != nil {
+			return sshPublicKey{}, fmt.Errorf("invalid certificate nonce: %s", err)
+		}
+	}
+	for i, field := range format.fields {
+		data, err := readSSHString(r)
+		if err != nil {
+			return sshPublicKey{}, fmt.Errorf("invalid key: %s", err)
+		}
+		if size, ok := format.keysize[i]; ok && len(data) != size {
+			return sshPublicKey{}, fmt.Errorf("invalid key: expected %d bytes, got %d", size, len(data))
+		}
+		if field == fieldMPInt && i == format.sizefield {
+			key.Bits = new(big.Int).SetBytes(data).BitLen()
+		}
+	}
+	if strings.HasPrefix(basetype, "ecdsa-sha2-") || strings.HasPrefix(basetype, "sk-ecdsa-sha2-") {
+		if err := checkECDSACurve(basetype, blob, cert); err != nil {
+			return sshPublicKey{}, err
+		}
+	}
+	// A certificate contains its serial, principals, validity, extensions,
+	// signature key and signature after the public key
+	if !cert && r.Len() > 0 {
+		return sshPublicKey{}, errors.New("invalid key: unexpected trailing data")
+	}
+	if cert && r.Len() == 0 {
+		return sshPublicKey{}, errors.New("invalid certificate: missing certificate fields")
+	}
+	return key, nil
+}
+
+// baseType returns the type of the key, or the type of the key certified
+// for certificates
+func (k sshPublicKey) baseType() (string, bool) {
+	if strings.HasSuffix(k.Type, sshCertSuffix) {
+		base := strings.TrimSuffix(k.Type, sshCertSuffix)
+		// sk certificates keep the @openssh.com suffix of their key type
+		if strings.HasPrefix(base, "sk-") {
+			base += "@openssh.com"
+		}
+		return base, true
+	}
+	return k.Type, false
+}
+
+// checkECDSACurve verifies that the curve encoded in an ecdsa key matches
+// its type, e.g nistp256 for ecdsa-sha2-nistp256
+func checkECDSACurve(basetype string, blob []byte, cert bool) error {
+	r := bytes.NewReader(blob)
+	readSSHString(r)
+	if cert {
+		readSSHString(r)
+	}
+	curve, err := readSSHString(r)
+	if err != nil {
+		return fmt.Errorf("invalid key: %s", err)
+	}
+	expected := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(basetype, "sk-"), "ecdsa-sha2-"), "@openssh.com")
+	if string(curve) != expected {
+		return fmt.Errorf("key curve '%s' does not match key type '%s'", curve, basetype)
+	}
+	return nil
+}
+
+func readSSHString(r *bytes.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, errors.New("truncated data")
+	}
+	if int64(length) > int64(r.Len()) {
+		return nil, errors.New("truncated data")
+	}
+	data := make([]byte, length)
+	r.Read(data)
+	return data, nil
+}
+
+// fingerprint returns the MD5 fingerprint of the key, e.g "b6:03:0e:...",
+// the format used by Gandi
+func (k sshPublicKey) fingerprint() string {
+	sum := md5.Sum(k.Blob)
+	hexsum := hex.EncodeToString(sum[:])
+	var parts []string
+	for i := 0; i < len(hexsum); i += 2 {
+		parts = append(parts, hexsum[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// fingerprintSHA256 returns the SHA256 fingerprint of the key,
+// e.g "SHA256:Lwh...", the format used by recent OpenSSH versions
+func (k sshPublicKey) fingerprintSHA256() string {
+	sum := sha256.Sum256(k.Blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// matchesFingerprint compares the key with a fingerprint in MD5,
+// with or without colons, or SHA256 format
+func (k sshPublicKey) matchesFingerprint(fingerprint string) bool {
+	if strings.HasPrefix(fingerprint, "SHA256:") {
+		return fingerprint == k.fingerprintSHA256()
+	}
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Replace(strings.TrimPrefix(s, "MD5:"), ":", "", -1))
+	}
+	return normalize(fingerprint) == normalize(k.fingerprint())
+}
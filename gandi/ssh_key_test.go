@@ -0,0 +1,133 @@
+package gandi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+// generated with ssh-keygen
+const (
+	testSSHKeyRSA2048  = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDXIeUp52s6Ls9ksx+ujkIv1tONtUydXTqzShgk1N0D72i6K/iRdVGDqdMGdv3l9CZTokjSqlmPx91Ha5TQLDTYXK0AHzMgEbNFWOy9WsxbnFAI9omp1Uwl3bjLU67FADRwDz/dRA8YuUwudddv/2vc1h3eybwsU7Q78Bg1V+jeuP/1JCIni9L1F0DrXA/gRGqGUXLvNypNIS250PBB5lxQ8KWutAzvb+puTQ//MrJhKOLK3+3b+4qwAlPB6Q7b+yRJmlkchiWkrosMGmG4iHZ1nuvcs9NItgevVfVcU1JTr9UOO+8IaCZMn+vD3jBTIVeVuwOZBAA2JBx48MythUcL test"
+	testSSHKeyRSA1024  = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDCRKKctfiwrrtEGf40ofDdid4aCXkmUUHSSopTDcUa2gO6kZGWm7GDmbQEsuINWjZeUCj0pn/6ZvafPjgz0IW9LfphdVGA+yGzPgunaPbebMGR6NH4QvjFA3t4NP19x7TRInWB/vmXv/b6YDMN7ghP1MVgjlyeamHKxSVUkTfTdQ== test"
+	testSSHKeyDSA      = "ssh-dss AAAAB3NzaC1kc3MAAACBANo7cpWRhYf4zemI32nx60mgfyRKZFq6xD/TgggO3yIFaYUavw01uC59mCR2tonaWMTT/5NmPu1GJmx9W1EjJlo3QXtEb0OfY/wMOaI3cf9r6OF2wae61Jr5AwmUDvq7sTkBNqdpXHFHOzsCGKhiY5md9Z7koxjgCd7hccmCi+udAAAAFQDCQqr995SNqASV9eaUZJekrLzmxwAAAIAewMtiEy3lmYt99rK7ast+1+bBQuboBZtPNs667pNd+K1CRGQlVGucJr5TBzk2jtmOJRcktp+nGen/0qB0/i70KXWjmA2zpfgWfwhoy45b0TObMjtC+j3uxmSooIphPMdfHA26B1YMkNcGWmlnxFX6P9u7WfWm+mlbIVxidYSRaQAAAIBHmBNqIcxYhpDWPtf0IxOA1UZb6l2kTWxN65ELfwT7cU91Vs5b/KqC1+wBvZCtsNrrW+k0b4e6/iSXEZ7ywKMP2kLxvKtSsO5bKIukCM2Ltc+4P07rHFDKAhNyiprmO6cld+O7CGNR24zFvkPal0t/3id+kD93i3OWLKR/lH8EFg== test"
+	testSSHKeyEd25519  = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIPp96u9+a5p9wgjjyIpc5QhpLtsQJHx/JSBHeDJP90sw test"
+	testSSHKeyNISTP256 = "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBJHm5EYnBpln7qtz0Y3qR7yUN6hoJWuZWhKfDtGs9cU8oS3/FbeIgEgb7i3QR0ndlYOQvkokPYmV3r+TvCJgV7I= test"
+	testSSHKeyNISTP384 = "ecdsa-sha2-nistp384 AAAAE2VjZHNhLXNoYTItbmlzdHAzODQAAAAIbmlzdHAzODQAAABhBEjgPV4f2F5JTn/djuaxofLfJrbCjyjZpPM0ZVHJzHthAI4xT0hkhc7uU7N8K4QIktIznORza7cWFsTWY8f+vBNNVibRIyU0nqAOX1is9e8Hj9J2ON4onvHMBc43X03F+Q== test"
+	testSSHKeyNISTP521 = "ecdsa-sha2-nistp521 AAAAE2VjZHNhLXNoYTItbmlzdHA1MjEAAAAIbmlzdHA1MjEAAACFBAFvKTUDw+izQ87saMpx/6TGeE1yh3tPZOF49gGLzVJHspAXr3N9MWI3GljyC/SLCuRmYsQ1DoTGyR2MgYk0BOhkNwFQvhQtwpsEe4LsZf3CGX1y18vDJgZVLLdH9YFDiEP+BbkjF6Azxw5Rgt7NciovsMlurFUY/D3jsJSlWCdaJYgWRg== test"
+	testSSHCertEd25519 = "ssh-ed25519-cert-v01@openssh.com AAAAIHNzaC1lZDI1NTE5LWNlcnQtdjAxQG9wZW5zc2guY29tAAAAIMo5sM1VDSgqetIbskV+MBKi/jsjvLCrYDclPafDlbhHAAAAIPp96u9+a5p9wgjjyIpc5QhpLtsQJHx/JSBHeDJP90swAAAAAAAAAAAAAAABAAAABHRlc3QAAAAIAAAABHJvb3QAAAAAAAAAAP//////////AAAAAAAAAIIAAAAVcGVybWl0LVgxMS1mb3J3YXJkaW5nAAAAAAAAABdwZXJtaXQtYWdlbnQtZm9yd2FyZGluZwAAAAAAAAAWcGVybWl0LXBvcnQtZm9yd2FyZGluZwAAAAAAAAAKcGVybWl0LXB0eQAAAAAAAAAOcGVybWl0LXVzZXItcmMAAAAAAAAAAAAAARcAAAAHc3NoLXJzYQAAAAMBAAEAAAEBANch5Snnazouz2SzH66OQi/W0421TJ1dOrNKGCTU3QPvaLor+JF1UYOp0wZ2/eX0JlOiSNKqWY/H3UdrlNAsNNhcrQAfMyARs0VY7L1azFucUAj2ianVTCXduMtTrsUANHAPP91EDxi5TC5112//a9zWHd7JvCxTtDvwGDVX6N64//UkIieL0vUXQOtcD+BEaoZRcu83Kk0hLbnQ8EHmXFDwpa60DO9v6m5ND/8ysmEo4srf7dv7irACU8HpDtv7JEmaWRyGJaSuiwwaYbiIdnWe69yz00i2B69V9VxTUlOv1Q477whoJkyf68PeMFMhV5W7A5kEADYkHHjwzK2FRwsAAAEUAAAADHJzYS1zaGEyLTUxMgAAAQBIV7T4uYuF8aZVYietAcyoUTG9Y4AnmUKpkfJ+oNgKyyUzLphjl+YBzMxg2F7vqYZW7eIAFm3pulUmk+dtN6eRCGfNjC7CKPNptmukOS2inrXKitVpf0sZiU9G7KGZ0+YdYOh03xqlEZo6haJhVeYcaxAZ81WTGnoUCyjWv3+b5CDFBqRGbK0MdGOCUb8iAEk90BYmyOrvOZodedsmWDqJmiK8/Qyf0nJ9B3xtOSHhumUxEJmv//TIhLvKkKWYXxtlHD+UtUwD9Vfs1Ymi+PMV3etVO1qwrpBuBXaCTMpLLM/nSRu3SGOEKpjFix6hIwSL/MBHD6ZCRmaOM+ba14KB test"
+)
+
+// testSSHKey builds a key in the authorized_keys format from its fields
+func testSSHKey(keytype string, fields ...[]byte) string {
+	var blob bytes.Buffer
+	for _, field := range append([][]byte{[]byte(keytype)}, fields...) {
+		binary.Write(&blob, binary.BigEndian, uint32(len(field)))
+		blob.Write(field)
+	}
+	return keytype + " " + base64.StdEncoding.EncodeToString(blob.Bytes())
+}
+
+func TestGandi_parseSSHPublicKey(t *testing.T) {
+	ed25519 := bytes.Repeat([]byte{1}, 32)
+	point := append([]byte{4}, bytes.Repeat([]byte{1}, 64)...)
+	cases := []struct {
+		Value       string
+		Bits        int
+		Fingerprint string
+		Comment     string
+		Error       bool
+	}{
+		{testSSHKeyRSA2048, 2048, "bf:6e:22:5b:d9:c7:91:b1:7b:7b:ae:85:a0:db:1e:e9", "test", false},
+		{testSSHKeyRSA1024, 1024, "78:10:5b:b7:f0:94:09:47:57:a2:9a:2a:a5:1b:32:2a", "test", false},
+		{testSSHKeyDSA, 1024, "84:27:eb:f3:eb:89:8d:25:de:d9:ce:7e:f3:5e:a1:64", "test", false},
+		{testSSHKeyEd25519, 0, "95:26:ae:5c:fc:01:fc:9e:9b:84:c1:bf:c7:37:e5:da", "test", false},
+		{testSSHKeyNISTP256, 0, "b6:80:0a:8e:98:fa:cb:88:65:38:c1:f8:3d:f9:40:cc", "test", false},
+		{testSSHKeyNISTP384, 0, "8a:b8:6c:b6:ab:f2:03:dd:b1:dc:26:09:b2:7c:b7:c1", "test", false},
+		{testSSHKeyNISTP521, 0, "83:d2:78:23:d4:76:64:63:ed:c3:e8:5d:e6:c4:fb:7f", "test", false},
+		{testSSHCertEd25519, 0, "", "test", false},
+		// no comment
+		{testSSHKey("ssh-ed25519", ed25519), 0, "", "", false},
+		{testSSHKey("sk-ssh-ed25519@openssh.com", ed25519, []byte("ssh:")) + " yubikey", 0, "", "yubikey", false},
+		{testSSHKey("sk-ecdsa-sha2-nistp256@openssh.com", []byte("nistp256"), point, []byte("ssh:")), 0, "", "", false},
+		// wrong curve for the key type
+		{testSSHKey("ecdsa-sha2-nistp256", []byte("nistp384"), point), 0, "", "", true},
+		// wrong key size
+		{testSSHKey("ssh-ed25519", ed25519[:16]), 0, "", "", true},
+		// trailing data
+		{testSSHKey("ssh-ed25519", ed25519, []byte("x")), 0, "", "", true},
+		// certificate without its certificate fields
+		{testSSHKey("ssh-ed25519-cert-v01@openssh.com", []byte("nonce"), ed25519), 0, "", "", true},
+		// declared type differs from the encoded type
+		{"ssh-rsa" + testSSHKeyEd25519[len("ssh-ed25519"):], 0, "", "", true},
+		{"ssh-foo AAAAB3NzaC1mb28=", 0, "", "", true},
+		{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIPp96u9", 0, "", "", true},
+		{"ssh-ed25519 not%base64", 0, "", "", true},
+		{"ssh-ed25519", 0, "", "", true},
+		{"", 0, "", "", true},
+	}
+
+	for i, c := range cases {
+		key, err := parseSSHPublicKey(c.Value)
+		if (err != nil) != c.Error {
+			t.Fatalf("Error in case %d, expected error %t, got %v instead", i, c.Error, err)
+		}
+		if c.Error {
+			continue
+		}
+		if key.Bits != c.Bits {
+			t.Fatalf("Error in case %d, expected %d bits, got %d instead", i, c.Bits, key.Bits)
+		}
+		if key.Comment != c.Comment {
+			t.Fatalf("Error in case %d, expected comment '%s', got '%s' instead", i, c.Comment, key.Comment)
+		}
+		if c.Fingerprint != "" && key.fingerprint() != c.Fingerprint {
+			t.Fatalf("Error in case %d, expected fingerprint %s, got %s instead", i, c.Fingerprint, key.fingerprint())
+		}
+	}
+}
+
+func TestGandi_matchesFingerprint(t *testing.T) {
+	key, _ := parseSSHPublicKey(testSSHKeyEd25519)
+	cases := []struct {
+		Fingerprint string
+		Expected    bool
+	}{
+		{"95:26:ae:5c:fc:01:fc:9e:9b:84:c1:bf:c7:37:e5:da", true},
+		{"MD5:95:26:AE:5C:FC:01:FC:9E:9B:84:C1:BF:C7:37:E5:DA", true},
+		{"9526ae5cfc01fc9e9b84c1bfc737e5da", true},
+		{key.fingerprintSHA256(), true},
+		{"SHA256:AAAA", false},
+		{"bf:6e:22:5b:d9:c7:91:b1:7b:7b:ae:85:a0:db:1e:e9", false},
+	}
+
+	for i, c := range cases {
+		if got := key.matchesFingerprint(c.Fingerprint); got != c.Expected {
+			t.Fatalf("Error in case %d, expected %t, got %t instead", i, c.Expected, got)
+		}
+	}
+}
+
+func TestGandi_checkSSHKeyStrength(t *testing.T) {
+	cases := []struct {
+		Value   string
+		MinBits int
+		Error   bool
+	}{
+		{testSSHKeyRSA1024, 0, false},
+		{testSSHKeyDSA, 0, false},
+		{testSSHKeyRSA1024, 2048, true},
+		{testSSHKeyRSA2048, 2048, false},
+		{testSSHKeyRSA2048, 4096, true},
+		{testSSHKeyDSA, 1024, true},
+		{testSSHKeyEd25519, 4096, false},
+	}
+
+	for i, c := range cases {
+		key, _ := parseSSHPublicKey(c.Value)
+		if err := checkSSHKeyStrength(key, c.MinBits); (err != nil) != c.Error {
+			t.Fatalf("Error in case %d, expected error %t, got %v instead", i, c.Error, err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package gandi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PabloPie/go-gandi/client"
+	"github.com/PabloPie/go-gandi/hosting"
+	"github.com/PabloPie/go-gandi/hosting/hostingv4"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+// Sweepers delete what failed acceptance tests left behind, they are run
+// against a test account with e.g `go test ./gandi -v -sweep=FR-SD6`,
+// `-sweep=all` sweeps every region
+//
+// Objects are recognized by the prefix of the names used by the tests,
+// ips have no name and are deleted with the test vm or vlan they are
+// attached to. Detached ips are only swept when GANDI_SWEEP_DETACHED_IPS
+// is set, c.f testSweepIPs
+func TestMain(m *testing.M) {
+	resource.TestMain(m)
+}
+
+const (
+	testSweepVMPrefix   = "gandivm-"
+	testSweepDiskPrefix = "gandidisk"
+	testSweepSSHPrefix  = "gandissh"
+	testSweepVlanPrefix = "gandivlan"
+)
+
+func init() {
+	resource.AddTestSweepers("gandi_vm", &resource.Sweeper{
+		Name: "gandi_vm",
+		F:    testSweepVMs,
+	})
+	resource.AddTestSweepers("gandi_disk", &resource.Sweeper{
+		Name:         "gandi_disk",
+		Dependencies: []string{"gandi_vm"},
+		F:            testSweepDisks,
+	})
+	resource.AddTestSweepers("gandi_ip", &resource.Sweeper{
+		Name:         "gandi_ip",
+		Dependencies: []string{"gandi_vm"},
+		F:            testSweepIPs,
+	})
+	resource.AddTestSweepers("gandi_vlan", &resource.Sweeper{
+		Name:         "gandi_vlan",
+		Dependencies: []string{"gandi_vm"},
+		F:            testSweepVlans,
+	})
+	resource.AddTestSweepers("gandi_ssh", &resource.Sweeper{
+		Name: "gandi_ssh",
+		F:    testSweepSSHKeys,
+	})
+}
+
+// sharedHosting returns a client built from the environment and the ID of
+// the region to sweep, empty for every region
+func sharedHosting(region string) (hosting.Hosting, string, error) {
+	apikey := os.Getenv("GANDI_API_KEY")
+	if apikey == "" {
+		return nil, "", fmt.Errorf("GANDI_API_KEY must be set for sweepers")
+	}
+	gandiClient, err := client.NewClientv4(os.Getenv("GANDI_API_URL"), apikey)
+	if err != nil {
+		return nil, "", err
+	}
+	h := hostingv4.Newv4Hosting(gandiClient)
+	if region == "" || region == "all" {
+		return h, "", nil
+	}
+	r, err := resolveRegion(h, region)
+	if err != nil {
+		return nil, "", err
+	}
+	return h, r.ID, nil
+}
+
+// testSweepVMs deletes the test vms with every disk and ip attached,
+// these are only used by the vm
+func testSweepVMs(region string) error {
+	h, regionid, err := sharedHosting(region)
+	if err != nil {
+		return err
+	}
+	vms, err := h.ListVMs(hosting.VMFilter{RegionID: regionid})
+	if err != nil {
+		return err
+	}
+	var errs *multierror.Error
+	for _, vm := range vms {
+		if !strings.HasPrefix(vm.Hostname, testSweepVMPrefix) {
+			continue
+		}
+		log.Printf("[INFO] Sweeping vm '%s'(%s)", vm.Hostname, vm.ID)
+		if err := setVMPowerState(h, vm, "halted", 10*time.Minute); err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		for _, disk := range vm.Disks {
+			if _, _, err := h.DetachDisk(vm, disk); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("Could not detach disk '%s': %s", disk.Name, err))
+			}
+		}
+		// The ipv6 created with an ipv4 shares its interface, which is
+		// detached once
+		ifaces, err := ipInterfaces(h, vm.Ips)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		for _, ip := range configurableIPs(vm.Ips, ifaces) {
+			if _, _, err := h.DetachIP(vm, ip); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("Could not detach ip '%s': %s", ip.IP, err))
+			}
+		}
+		if err := h.DeleteVM(vm); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("Could not delete vm '%s': %s", vm.Hostname, err))
+			continue
+		}
+		errs = multierror.Append(errs, deleteDisks(h, vm.Disks))
+		errs = multierror.Append(errs, deleteIPs(h, vm.Ips))
+	}
+	return errs.ErrorOrNil()
+}
+
+func testSweepDisks(region string) error {
+	h, regionid, err := sharedHosting(region)
+	if err != nil {
+		return err
+	}
+	disks, err := h.ListDisks(hosting.DiskFilter{RegionID: regionid})
+	if err != nil {
+		return err
+	}
+	var errs *multierror.Error
+	for _, disk := range disks {
+		if !strings.HasPrefix(disk.Name, testSweepDiskPrefix) {
+			continue
+		}
+		log.Printf("[INFO] Sweeping disk '%s'(%s)", disk.Name, disk.ID)
+		for _, vmid := range disk.VM {
+			vm := hosting.VM{ID: vmid, RegionID: disk.RegionID}
+			if _, _, err := h.DetachDisk(vm, disk); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("Could not detach disk '%s' from vm %s: %s", disk.Name, vmid, err))
+			}
+		}
+		errs = multierror.Append(errs, deleteDisks(h, []hosting.Disk{disk}))
+	}
+	return errs.ErrorOrNil()
+}
+
+// testSweepIPs deletes the ips attached to no vm when
+// GANDI_SWEEP_DETACHED_IPS is set. Ips have no name to tell the ones created
+// by the tests apart: every detached public ip of the region is deleted,
+// including the ones reserved outside of the tests, private ips only when
+// they are in a test vlan
+func testSweepIPs(region string) error {
+	if os.Getenv("GANDI_SWEEP_DETACHED_IPS") == "" {
+		log.Printf("[INFO] Skipping detached ips, set GANDI_SWEEP_DETACHED_IPS to sweep them")
+		return nil
+	}
+	h, regionid, err := sharedHosting(region)
+	if err != nil {
+		return err
+	}
+	vlans, err := h.ListVlans(vlanRegionFilter(regionid))
+	if err != nil {
+		return err
+	}
+	ips, err := h.ListIPs(hosting.IPFilter{RegionID: regionid})
+	if err != nil {
+		return err
+	}
+	var detached []hosting.IPAddress
+	for _, ip := range ips {
+		if ipAttached(ip) {
+			continue
+		}
+		if vlan, ok := ipVlan(vlans, ip); ok && !strings.HasPrefix(vlan.Name, testSweepVlanPrefix) {
+			continue
+		}
+		log.Printf("[INFO] Sweeping ip '%s'(%s)", ip.IP, ip.ID)
+		detached = append(detached, ip)
+	}
+	return deleteIPs(h, detached)
+}
+
+// testSweepVlans deletes the test vlans and their private ips, detaching
+// them from their vm first
+func testSweepVlans(region string) error {
+	h, regionid, err := sharedHosting(region)
+	if err != nil {
+		return err
+	}
+	vlans, err := h.ListVlans(vlanRegionFilter(regionid))
+	if err != nil {
+		return err
+	}
+	ips, err := h.ListIPs(hosting.IPFilter{RegionID: regionid, Version: hosting.IPv4})
+	if err != nil {
+		return err
+	}
+	var errs *multierror.Error
+	for _, vlan := range vlans {
+		if !strings.HasPrefix(vlan.Name, testSweepVlanPrefix) {
+			continue
+		}
+		log.Printf("[INFO] Sweeping vlan '%s'(%s)", vlan.Name, vlan.ID)
+		var privateips []hosting.IPAddress
+		for _, ip := range ips {
+			if _, ok := ipVlan([]hosting.Vlan{vlan}, ip); !ok {
+				continue
+			}
+			if ipAttached(ip) {
+				vm := hosting.VM{ID: ip.VM, RegionID: ip.RegionID}
+				if _, _, err := h.DetachIP(vm, ip); err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("Could not detach ip '%s' from vm %s: %s", ip.IP, ip.VM, err))
+					continue
+				}
+			}
+			privateips = append(privateips, ip)
+		}
+		if err := deleteIPs(h, privateips); err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if err := h.DeleteVlan(vlan); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("Could not delete vlan '%s': %s", vlan.Name, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// SSH keys are not tied to a region, they are swept for every region
+func testSweepSSHKeys(region string) error {
+	h, _, err := sharedHosting(region)
+	if err != nil {
+		return err
+	}
+	var errs *multierror.Error
+	for _, sshkey := range h.ListKeys() {
+		if !strings.HasPrefix(sshkey.Name, testSweepSSHPrefix) {
+			continue
+		}
+		log.Printf("[INFO] Sweeping ssh key '%s'(%s)", sshkey.Name, sshkey.ID)
+		if err := h.DeleteKey(sshkey); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("Could not delete ssh key '%s': %s", sshkey.Name, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
@@ -1,12 +1,40 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+
 	"github.com/PabloPie/terraform-provider-gandi/gandi"
 	"github.com/hashicorp/terraform/plugin"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		generate(os.Args[2:])
+		return
+	}
 	plugin.Serve(&plugin.ServeOpts{
 		ProviderFunc: gandi.Provider,
 	})
 }
+
+// generate writes the configuration of an existing Gandi account,
+// the API key and URL are read from the environment like the provider's
+func generate(args []string) {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	region := flags.String("region", "", "Region code or ID to generate, every region if empty")
+	out := flags.String("out", ".", "Directory gandi.tf and import.sh are written to")
+	flags.Parse(args)
+
+	err := gandi.Generate(gandi.GenerateOptions{
+		APIKey: os.Getenv("GANDI_API_KEY"),
+		URL:    os.Getenv("GANDI_API_URL"),
+		Region: *region,
+		OutDir: *out,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}